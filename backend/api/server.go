@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// Server exposes the job manager over a loopback-only HTTP API so other local
+// tools can submit and monitor upscale jobs. Every request must carry the token,
+// either as "Authorization: Bearer <token>" or as ?token= for server-sent events.
+type Server struct {
+	logger       *utils.CustomLogger
+	jobManager   *backend.JobManager
+	jobsFolder   string
+	outputFolder string
+	token        string
+	prepare      backend.PrepareRequestFunc
+
+	httpServer *http.Server
+	listener   net.Listener
+}
+
+type SubmitJobRequest struct {
+	InputPath    string                         `json:"inputPath"`
+	Preset       string                         `json:"preset"` // overrides model, scale and streaming when set
+	Model        string                         `json:"model"`
+	Scale        int                            `json:"scale"`
+	Passes       []datatransfers.UpscalePass    `json:"passes"`       // optional, chained models replacing model and scale
+	OutputFolder string                         `json:"outputFolder"` // optional, defaults to the app output folder
+	Streaming    bool                           `json:"streaming"`
+	Target       datatransfers.TargetResolution `json:"target"`    // optional, replaces scale
+	PreScale     datatransfers.PreScaleOptions  `json:"preScale"`  // optional, default : auto
+	StartTime    string                         `json:"startTime"` // optional trim range, "90.5" or "00:01:30.5"
+	EndTime      string                         `json:"endTime"`
+	StartFrame   int                            `json:"startFrame"` // trim range as frame numbers, used when the times are empty
+	EndFrame     int                            `json:"endFrame"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// GenerateToken returns a random token for the API server.
+func GenerateToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func NewServer(logger *utils.CustomLogger, jobManager *backend.JobManager, jobsFolder, outputFolder, token string, prepare backend.PrepareRequestFunc) *Server {
+	return &Server{
+		logger:       logger,
+		jobManager:   jobManager,
+		jobsFolder:   jobsFolder,
+		outputFolder: outputFolder,
+		token:        token,
+		prepare:      prepare,
+	}
+}
+
+// Start listens on 127.0.0.1:port (0 picks a free port) and serves in the background.
+func (s *Server) Start(port int) error {
+	if s.token == "" {
+		return fmt.Errorf("an API token is required")
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/jobs", s.handleSubmitJob)
+	mux.HandleFunc("GET /api/jobs", s.handleListJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.handleCancelJob)
+	mux.HandleFunc("DELETE /api/jobs/{id}", s.handleDiscardJob)
+	mux.HandleFunc("GET /api/jobs/{id}/result", s.handleJobResult)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+
+	s.listener = listener
+	s.httpServer = &http.Server{
+		Handler:           s.authorize(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error(fmt.Sprintf("API server stopped: %v", err))
+		}
+	}()
+
+	s.logger.Info(fmt.Sprintf("API server listening on http://%s", s.Addr()))
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Token returns the token clients must send.
+func (s *Server) Token() string {
+	return s.token
+}
+
+// Shutdown stops the server, waiting for in-flight requests until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var body SubmitJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid body: " + err.Error()})
+		return
+	}
+	if body.InputPath == "" || (body.Preset == "" && len(body.Passes) == 0 && (body.Model == "" || (body.Scale <= 0 && body.Target.Mode == constants.TargetModeNone))) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "inputPath and either preset, passes or model and scale or target are required"})
+		return
+	}
+
+	outputFolder := body.OutputFolder
+	if outputFolder == "" {
+		outputFolder = s.outputFolder
+	}
+
+	request, err := backend.NewFileRequest(body.InputPath, s.jobsFolder, outputFolder)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	request.Model = body.Model
+	request.ScaleMultiplier = body.Scale
+	request.Passes = body.Passes
+	request.Streaming = body.Streaming
+	request.Target = body.Target
+	if body.PreScale.Policy != "" {
+		request.PreScale = body.PreScale
+	}
+	request.StartTime, request.EndTime = body.StartTime, body.EndTime
+	request.StartFrame, request.EndFrame = body.StartFrame, body.EndFrame
+	if s.prepare != nil {
+		if err := s.prepare(request, body.Preset); err != nil {
+			os.RemoveAll(request.TempDir)
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	jobID := s.jobManager.Submit(request)
+	info, _ := s.jobManager.Get(jobID)
+	writeJSON(w, http.StatusAccepted, info)
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.jobManager.ListJobs())
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	info, err := s.jobManager.Get(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	if err := s.jobManager.CancelJob(r.PathValue("id")); err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	info, _ := s.jobManager.Get(r.PathValue("id"))
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleDiscardJob deletes the files of a failed, cancelled or interrupted job.
+func (s *Server) handleDiscardJob(w http.ResponseWriter, r *http.Request) {
+	err := s.jobManager.DiscardJob(filepath.Join(s.jobsFolder, filepath.Base(r.PathValue("id"))))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, backend.ErrJobNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case errors.Is(err, backend.ErrJobActive):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+	}
+}
+
+func (s *Server) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	info, err := s.jobManager.Get(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	switch info.State {
+	case constants.JobStateDone:
+		writeJSON(w, http.StatusOK, map[string]string{"savePath": info.SavePath})
+	case constants.JobStateFailed, constants.JobStateCancelled:
+		writeJSON(w, http.StatusGone, errorResponse{Error: fmt.Sprintf("job %s: %s", info.State, info.Error)})
+	default:
+		writeJSON(w, http.StatusConflict, errorResponse{Error: fmt.Sprintf("job is %s", info.State)})
+	}
+}
+
+// handleEvents streams progress events as server-sent events, optionally
+// filtered to a single job with ?job=<id>.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "streaming unsupported"})
+		return
+	}
+
+	jobFilter := r.URL.Query().Get("job")
+	events, unsubscribe := s.jobManager.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			if jobFilter != "" && event.JobID != jobFilter {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", constants.EventJobProgress, data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// stubUpscaler fails the jobs whose save path is "fail.mp4", the other methods are not used.
+type stubUpscaler struct {
+	backend.VideoUpscalerUsecase
+}
+
+func (s *stubUpscaler) UpscaleVideoWithRealESRGAN(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+	if params.SavePath == "fail.mp4" {
+		return errors.New("encoder crashed")
+	}
+	return nil
+}
+
+func (s *stubUpscaler) Progress() <-chan datatransfers.ProgressEvent {
+	return nil
+}
+
+func TestJobResult(t *testing.T) {
+	logger, err := utils.NewCustomLogger(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobManager := backend.NewJobManager(logger, &stubUpscaler{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobManager.Start(ctx)
+
+	server := NewServer(logger, jobManager, t.TempDir(), t.TempDir(), "secret", nil)
+	if err := server.Start(0); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown(context.Background())
+
+	tests := []struct {
+		savePath   string
+		wantStatus int
+	}{
+		{"out.mp4", http.StatusOK},
+		{"fail.mp4", http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.savePath, func(t *testing.T) {
+			id := jobManager.Submit(&datatransfers.VideoUpscalerRequest{TempDir: filepath.Join(t.TempDir(), "job"), SavePath: tt.savePath})
+			if _, err := jobManager.Wait(id); err != nil {
+				t.Fatal(err)
+			}
+
+			request, _ := http.NewRequest(http.MethodGet, "http://"+server.Addr()+"/api/jobs/"+id+"/result", nil)
+			request.Header.Set("Authorization", "Bearer secret")
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				var body map[string]string
+				if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body["savePath"] != tt.savePath {
+					t.Errorf("body = %v, err = %v", body, err)
+				}
+			}
+		})
+	}
+}
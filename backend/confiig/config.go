@@ -10,7 +10,8 @@ import (
 type AppPaths struct {
 	FFmpegPath     string
 	FFprobePath    string
-	RealEsrganPath string
+	RealEsrganPath string // empty when Real-ESRGAN is not available
+	ModelsDir      string // Real-ESRGAN models dir
 }
 
 var (
@@ -25,8 +26,9 @@ func InitializePaths(sessionApps *sync.Map) error {
 		ffmpegPath, ok1 := sessionApps.Load(constants.CtxFFmpegPath)
 		ffprobePath, ok2 := sessionApps.Load(constants.CtxFFprobePath)
 		realesrganPath, ok3 := sessionApps.Load(constants.CtxRealesrganPath)
+		modelsDir, ok4 := sessionApps.Load(constants.CtxModelsDir)
 
-		if !ok1 || !ok2 || !ok3 {
+		if !ok1 || !ok2 || !ok3 || !ok4 {
 			err = fmt.Errorf("missing paths in sessionApps")
 			return
 		}
@@ -35,6 +37,7 @@ func InitializePaths(sessionApps *sync.Map) error {
 			FFmpegPath:     ffmpegPath.(string),
 			FFprobePath:    ffprobePath.(string),
 			RealEsrganPath: realesrganPath.(string),
+			ModelsDir:      modelsDir.(string),
 		}
 	})
 	return err
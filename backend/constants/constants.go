@@ -1,5 +1,7 @@
 package constants
 
+import "time"
+
 type LogLevel string
 
 const (
@@ -11,16 +13,146 @@ const (
 	LogLevelFatal   LogLevel = "fatal"
 )
 
+type JobState string
+
+const (
+	JobStateQueued    JobState = "queued"
+	JobStateRunning   JobState = "running"
+	JobStateCancelled JobState = "cancelled"
+	JobStateFailed    JobState = "failed"
+	JobStateDone      JobState = "done"
+)
+
+// JobFailure tells why a failed job failed.
+type JobFailure string
+
+const (
+	JobFailureOther  JobFailure = ""       // settings, disk or anything not listed below
+	JobFailureInput  JobFailure = "input"  // the source can't be found, probed or decoded
+	JobFailureEngine JobFailure = "engine" // the upscale engine is unusable or failed
+	JobFailureEncode JobFailure = "encode" // encoding, audio extraction or muxing failed
+)
+
+type ProgressStage string
+
+const (
+	ProgressStageSetup     ProgressStage = "setup"
+	ProgressStageProbing   ProgressStage = "probing"
+	ProgressStageAudio     ProgressStage = "extracting_audio"
+	ProgressStageUpscaling ProgressStage = "upscaling"
+	ProgressStageMerging   ProgressStage = "merging"
+	ProgressStageCleanup   ProgressStage = "cleanup"
+	ProgressStageDone      ProgressStage = "done"
+)
+
+const (
+	EventJobProgress = "job_progress"
+)
+
 const (
-	CtxKeyRootTempDir = "rootTempDir"
 	CtxAppName        = "appName"
 	CtxSessionID      = "sessionId"
 	CtxFFmpegPath     = "ffmpegPath"
 	CtxFFprobePath    = "ffprobePath"
 	CtxRealesrganPath = "realesrganPath"
+	CtxModelsDir      = "modelsDir"
+)
+
+const (
+	JobManifestFileName = "job.json"
+	JobsFolderName      = "jobs"
+
+	// Job dirs without a manifest untouched for this long are pruned at startup,
+	// younger ones may still be prepared by another instance
+	StaleJobDirAge = 24 * time.Hour
+)
+
+const (
+	DefaultVideoEncoder = "libx264"
+	DefaultCRF          = 18
+	DefaultContainer    = "mp4"
+	DefaultAudioBitrate = "192k"
+)
+
+const (
+	WatchFolderProcessedName = "processed"
+	WatchFolderFailedName    = "failed"
+)
+
+// FrameCountMethod tells how the number of frames of a video was found.
+type FrameCountMethod string
+
+const (
+	FrameCountHeader   FrameCountMethod = "nb_frames"         // listed in the container header
+	FrameCountEstimate FrameCountMethod = "duration_estimate" // duration x frame rate
+	FrameCountPackets  FrameCountMethod = "packet_count"      // ffprobe -count_packets
+	FrameCountDecode   FrameCountMethod = "decode_count"      // ffprobe -count_frames, decodes everything
+)
+
+// TargetMode picks how the output size is set, none keeps the scale multiplier.
+type TargetMode string
+
+const (
+	TargetModeNone   TargetMode = ""
+	TargetModeFit    TargetMode = "fit"    // largest size within width x height keeping the aspect ratio
+	TargetModeHeight TargetMode = "height" // the given height, the width follows the aspect ratio
+	TargetModeExact  TargetMode = "exact"  // exactly width x height, padded or cropped
+)
+
+// TargetFill is how an exact target with another aspect ratio is filled.
+type TargetFill string
+
+const (
+	TargetFillPad  TargetFill = "pad"  // black bars
+	TargetFillCrop TargetFill = "crop" // the edges are cut
+)
+
+// PreScalePolicy is how frames are shrunk before the model upscales them.
+type PreScalePolicy string
+
+const (
+	PreScaleAuto         PreScalePolicy = "auto"          // picked per model, default
+	PreScaleNone         PreScalePolicy = "none"          // the model gets the source size
+	PreScaleFactor       PreScalePolicy = "factor"        // multiply both sides by a factor, e.g. 0.5
+	PreScaleMaxDimension PreScalePolicy = "max_dimension" // shrink until the longest side fits
+)
+
+// AnimePreScaleMaxDimension caps the input of the anime models in auto mode, drawn
+// content rarely has more detail than 720p and smaller inputs upscale much faster.
+const AnimePreScaleMaxDimension = 1280
+
+// MaxFrameDimension is the longest frame side a plan may produce, the limit of
+// the supported encoders, checked up front so chained passes can't overshoot it.
+const MaxFrameDimension = 16384
+
+// Batches are cut on a scene change between MinBatchFrames and MaxBatchFrames,
+// without one every batch is MaxBatchFrames long.
+const (
+	MinBatchFrames        = 30
+	MaxBatchFrames        = 150
+	DefaultSceneThreshold = 0.3 // scene score of select, 0 : identical frames, 1 : nothing in common
+)
+
+// FrameRateMode picks how the upscaled frames are timed when reassembled.
+type FrameRateMode string
+
+const (
+	FrameRateModeAuto FrameRateMode = "auto" // vfr when the source has a variable frame rate, cfr otherwise
+	FrameRateModeCFR  FrameRateMode = "cfr"  // every frame lasts 1/frame rate
+	FrameRateModeVFR  FrameRateMode = "vfr"  // every frame keeps its source timestamp
+)
+
+type ToolSource string
+
+const (
+	ToolSourceConfigured ToolSource = "configured"
+	ToolSourceEmbedded   ToolSource = "embedded"
+	ToolSourcePath       ToolSource = "path"
 )
 
+// Environment variables overriding the tool discovery
 const (
-	FileTypeEmbedFFMPEG     = "ffmpeg"
-	FileTypeEmbedRealesrgan = "realesrgan"
+	EnvFFmpegPath     = "REVIVEPIXELS_FFMPEG"
+	EnvFFprobePath    = "REVIVEPIXELS_FFPROBE"
+	EnvRealesrganPath = "REVIVEPIXELS_REALESRGAN"
 )
@@ -0,0 +1,37 @@
+package datatransfers
+
+import (
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+)
+
+type JobInfo struct {
+	ID         string               `json:"id"`
+	FileName   string               `json:"fileName"`
+	State      constants.JobState   `json:"state"`
+	Error      string               `json:"error"`
+	Failure    constants.JobFailure `json:"failure"` // why a failed job failed
+	SavePath   string               `json:"savePath"`
+	Plan       ResolutionPlan       `json:"plan"` // planned at submit, the plan run once done, empty for resumed jobs
+	CreatedAt  time.Time            `json:"createdAt"`
+	StartedAt  time.Time            `json:"startedAt"`
+	FinishedAt time.Time            `json:"finishedAt"`
+	Progress   ProgressEvent        `json:"progress"` // latest progress event
+}
+
+type APIServerStatus struct {
+	Running bool   `json:"running"`
+	Address string `json:"address"`
+	Token   string `json:"token"`
+}
+
+type WatchFolderConfig struct {
+	Folder              string `json:"folder"`
+	Preset              string `json:"preset"` // overrides Model, Scale and Streaming when set
+	Model               string `json:"model"`
+	Scale               int    `json:"scale"`
+	OutputFolder        string `json:"outputFolder"` // optional, defaults to the app output folder
+	Streaming           bool   `json:"streaming"`
+	PollIntervalSeconds int    `json:"pollIntervalSeconds"` // optional, defaults to 5
+}
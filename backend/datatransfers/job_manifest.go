@@ -0,0 +1,32 @@
+package datatransfers
+
+import "time"
+
+// JobManifest is persisted next to the temp data of a job so that an
+// interrupted upscale can be resumed without redoing finished batches.
+type JobManifest struct {
+	JobID            string
+	InputHash        string // sha256 of the input video
+	Request          VideoUpscalerRequest
+	BatchSize        int
+	TotalFrames      int
+	TotalBatches     int
+	SceneCuts        []int        // first frame of every scene but the first, empty when not detected
+	Batches          []FrameRange // planned batches, ending on scene cuts when possible
+	CompletedBatches []CompletedBatch
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// FrameRange is a range of frames, EndFrame included.
+type FrameRange struct {
+	StartFrame int
+	EndFrame   int
+}
+
+type CompletedBatch struct {
+	Index      int
+	StartFrame int
+	EndFrame   int
+	VideoPath  string
+}
@@ -0,0 +1,16 @@
+package datatransfers
+
+import "github.com/riskibarqy/RevivePixels/backend/constants"
+
+type ProgressEvent struct {
+	JobID        string                  `json:"jobId"`
+	FileName     string                  `json:"fileName"`
+	Stage        constants.ProgressStage `json:"stage"`
+	Batch        int                     `json:"batch"`
+	TotalBatches int                     `json:"totalBatches"`
+	FramesDone   int                     `json:"framesDone"`
+	TotalFrames  int                     `json:"totalFrames"`
+	Percent      float64                 `json:"percent"`
+	ETASeconds   float64                 `json:"etaSeconds"`
+	FPS          float64                 `json:"fps"` // upscaling throughput
+}
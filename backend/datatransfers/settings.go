@@ -0,0 +1,75 @@
+package datatransfers
+
+import "github.com/riskibarqy/RevivePixels/backend/constants"
+
+type EncoderOptions struct {
+	Codec       string `json:"codec"`       // libx264, libx265, libsvtav1, libaom-av1, libvpx-vp9 or ffv1, default : libx264
+	CRF         *int   `json:"crf"`         // constant rate factor, 0 is lossless with libx264, nil : the encoder default (18 for libx264)
+	Bitrate     string `json:"bitrate"`     // e.g. 8M, replaces CRF when set
+	Preset      string `json:"preset"`      // speed preset, e.g. slow for x264/x265, 0-13 for SVT-AV1
+	Tune        string `json:"tune"`        // e.g. animation, film, grain
+	PixelFormat string `json:"pixelFormat"` // e.g. yuv420p or yuv420p10le for 10-bit, default : yuv420p
+}
+
+type ContainerInfo struct {
+	Name        string   `json:"name"`
+	VideoCodecs []string `json:"videoCodecs"`
+	AudioCodec  string   `json:"audioCodec"` // default encoder of audio that can't be copied
+	AudioCopy   []string `json:"audioCopy"`  // audio codecs kept as they are, empty : any
+}
+
+// AudioOptions encode the audio tracks the output container can't hold as they
+// are, the others are always copied.
+type AudioOptions struct {
+	Codec   string `json:"codec"`   // aac, libopus, flac, alac, ac3, libmp3lame or libvorbis, default : the container default
+	Bitrate string `json:"bitrate"` // e.g. 192k, ignored by lossless codecs, default : 192k
+}
+
+type EncoderInfo struct {
+	Codec        string   `json:"codec"`
+	Name         string   `json:"name"`
+	Available    bool     `json:"available"` // built into the ffmpeg in use
+	Lossless     bool     `json:"lossless"`
+	CRFMax       int      `json:"crfMax"`
+	DefaultCRF   int      `json:"defaultCrf"`
+	Presets      []string `json:"presets"`
+	Tunes        []string `json:"tunes"`
+	PixelFormats []string `json:"pixelFormats"`
+}
+
+// Preset bundles the upscale knobs of a VideoUpscalerRequest under a name.
+// Zero values fall back to the settings defaults.
+type Preset struct {
+	Name               string                  `json:"name"`
+	Model              string                  `json:"model"`
+	ScaleMultiplier    int                     `json:"scaleMultiplier"`
+	Passes             []UpscalePass           `json:"passes"` // chained models, replaces Model and ScaleMultiplier when set
+	TileSize           int                     `json:"tileSize"`
+	VideoFPS           int                     `json:"videoFps"`
+	FrameRateMode      constants.FrameRateMode `json:"frameRateMode"`
+	Target             TargetResolution        `json:"target"`
+	PreScale           PreScaleOptions         `json:"preScale"`
+	Streaming          bool                    `json:"streaming"`
+	StreamBufferFrames int                     `json:"streamBufferFrames"`
+	Encoder            EncoderOptions          `json:"encoder"`
+	Audio              AudioOptions            `json:"audio"`
+	Container          string                  `json:"container"`
+	Concurrency        int                     `json:"concurrency"`
+}
+
+type Settings struct {
+	OutputFolder string            `json:"outputFolder"` // empty : output_videos next to the executable, or the user Videos folder
+	TempFolder   string            `json:"tempFolder"`   // where job data goes, empty : next to the executable, or the user cache dir
+	Encoder      EncoderOptions    `json:"encoder"`
+	Audio        AudioOptions      `json:"audio"`
+	Container    string            `json:"container"`   // mp4, mkv, mov or webm, default : mp4
+	Concurrency  int               `json:"concurrency"` // parallel frame upscales, 0 : half the CPUs
+	ToolPaths    map[string]string `json:"toolPaths"`   // tool name -> binary, skips the discovery
+	Presets      []Preset          `json:"presets"`
+}
+
+// PresetExport is the file format of exported presets.
+type PresetExport struct {
+	Version int      `json:"version"`
+	Presets []Preset `json:"presets"`
+}
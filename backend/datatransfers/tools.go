@@ -0,0 +1,12 @@
+package datatransfers
+
+import "github.com/riskibarqy/RevivePixels/backend/constants"
+
+type ToolDiagnostic struct {
+	Tool     string               `json:"tool"`
+	Path     string               `json:"path"`
+	Source   constants.ToolSource `json:"source"`
+	Version  string               `json:"version"`
+	Required bool                 `json:"required"`
+	Error    string               `json:"error"` // empty when the tool is usable
+}
@@ -1,6 +1,53 @@
 package datatransfers
 
+import "github.com/riskibarqy/RevivePixels/backend/constants"
+
+// Rational is an exact frame rate, e.g. 24000/1001 for 23.976 fps.
+type Rational struct {
+	Num int `json:"num"`
+	Den int `json:"den"`
+}
+
+// TargetResolution asks for an output size instead of a scale multiplier.
+type TargetResolution struct {
+	Mode   constants.TargetMode `json:"mode"` // fit, height or exact, empty : use the scale multiplier
+	Width  int                  `json:"width"`
+	Height int                  `json:"height"`
+	Fill   constants.TargetFill `json:"fill"` // exact only, pad or crop, default : pad
+}
+
+// PreScaleOptions shrink the frames before upscaling.
+type PreScaleOptions struct {
+	Policy       constants.PreScalePolicy `json:"policy"`       // auto, none, factor or max_dimension, default : auto
+	Factor       float64                  `json:"factor"`       // factor policy, between 0 and 1
+	MaxDimension int                      `json:"maxDimension"` // max_dimension policy, longest side in pixels
+}
+
+// UpscalePass is one model run of a chained upscale.
+type UpscalePass struct {
+	Model string `json:"model"`
+	Scale int    `json:"scale"` // 0 : the largest scale of the model
+}
+
+// ResolutionPlan is how the source size becomes the output size, decided before upscaling.
+type ResolutionPlan struct {
+	SourceWidth    int                      `json:"sourceWidth"`
+	SourceHeight   int                      `json:"sourceHeight"`
+	PreScalePolicy constants.PreScalePolicy `json:"preScalePolicy"` // the policy applied, auto resolved
+	PreScaleFactor float64                  `json:"preScaleFactor"` // 1 when the source is not shrunk
+	InputWidth     int                      `json:"inputWidth"`     // handed to the model after the pre-scale
+	InputHeight    int                      `json:"inputHeight"`
+	Passes         []UpscalePass            `json:"passes"`     // run in order on the same frames, scales resolved
+	ModelScale     int                      `json:"modelScale"` // product of the pass scales
+	UpscaledWidth  int                      `json:"upscaledWidth"`
+	UpscaledHeight int                      `json:"upscaledHeight"`
+	FinalFilter    string                   `json:"finalFilter"` // resampling of the model output, empty when it already is the output size
+	OutputWidth    int                      `json:"outputWidth"`
+	OutputHeight   int                      `json:"outputHeight"`
+}
+
 type VideoUpscalerRequest struct {
+	JobID              string // defaults to the TempDir name
 	InputPlainFileName string // filename without extension
 	InputFullFileName  string
 	InputFileExt       string // .mp4, .mkv etc
@@ -8,15 +55,34 @@ type VideoUpscalerRequest struct {
 	TempFilePath       string
 	TempDir            string
 	Model              string
-	VideoFPS           int // if its not filled, it will automatically use default video fps
+	VideoFPS           int                     // forces a constant frame rate, if its not filled, it will automatically use the source frame rate
+	FrameRate          Rational                // frame rate of the output, filled from the source
+	FrameRateMode      constants.FrameRateMode // auto, cfr or vfr, default : auto
 	AudioFileName      string
-	ScaleMultiplier    int // realersgan params : scale multiplier 2, 3, 4 default : 4
-	TileSize           int // Real-ESRGAN parameter: Default = 0 (auto). Higher values improve detail but increase GPU memory usage.
+	ScaleMultiplier    int           // realersgan params : scale multiplier 2, 3, 4 default : 4, picked from Target when set
+	Passes             []UpscalePass // chained models run on the same frames, replaces Model and ScaleMultiplier when set
+	Target             TargetResolution
+	PreScale           PreScaleOptions
+	Plan               ResolutionPlan // filled before upscaling
+	TileSize           int            // Real-ESRGAN parameter: Default = 0 (auto). Higher values improve detail but increase GPU memory usage.
 	SavePath           string
 	IsHaveAudio        bool
-	LoadingProgress    int
 	TotalBatches       int
 	CurrentBatch       int
+	SceneThreshold     float64 // scene score starting a new batch, between 0 and 1, default : 0.3
+	FixedBatches       bool    // cut batches every 150 frames instead of on scene changes
+	Streaming          bool    // pipe raw frames between ffmpeg and the engine instead of writing png files
+	StreamBufferFrames int     // max frames buffered between streaming stages, default : 8
+	Encoder            EncoderOptions
+	Audio              AudioOptions
+	StartTime          string  // trim start, "90.5" or "00:01:30.5", takes precedence over StartFrame
+	EndTime            string  // trim end, empty : the end of the video
+	StartFrame         int     // trim start as a frame number
+	EndFrame           int     // trim end as a frame number, excluded, 0 : the end of the video
+	TrimStartSeconds   float64 // resolved trim range in seconds from the start of the file, both 0 when the whole video is upscaled
+	TrimEndSeconds     float64
+	Container          string // mp4, mkv, mov or webm, SavePath gets its extension. default : mp4
+	Concurrency        int    // parallel frame upscales for engines without directory mode, default : half the CPUs
 }
 
 type InputFileRequest struct {
@@ -25,13 +91,23 @@ type InputFileRequest struct {
 	FileName   string
 	Model      string
 	Scale      int
+	Streaming  bool
+	Preset     string           // overrides Model, Scale and Streaming when set
+	Target     TargetResolution // optional, replaces Scale
+	StartTime  string           // optional trim range
+	EndTime    string
 }
 
 type FFProbeStreamsMetadataResponse struct {
-	TotalFrames int
-	FPS         int
-	Height      int
-	Width       int
+	TotalFrames      int
+	FrameCountMethod constants.FrameCountMethod // how TotalFrames was found, duration_estimate may be a frame off
+	FrameRate        Rational                   // r_frame_rate, e.g. 24000/1001
+	AvgFrameRate     Rational                   // avg_frame_rate, differs from FrameRate when the frame rate is variable
+	IsVFR            bool                       // the frame rate is variable
+	FrameTimestamps  []float64                  // seconds from the first frame, only probed for vfr mode
+	StartOffset      float64                    // seconds from the start of the file to the first frame, e.g. when the audio starts first
+	Height           int
+	Width            int
 }
 
 type VideoInfoRequest struct {
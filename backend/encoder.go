@@ -0,0 +1,275 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// encoderSpec describes how the options of a video encoder map to ffmpeg flags.
+type encoderSpec struct {
+	name              string
+	crfMax            int // 0 when the encoder is lossless and has no CRF
+	defaultCRF        int
+	presetFlag        string
+	presets           []string
+	tuneFlag          string
+	tunes             []string
+	pixelFormats      []string // the first one is the default
+	zeroBitrateForCRF bool     // constant quality needs -b:v 0
+	extraArgs         []string
+}
+
+var (
+	x26xPresets      = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+	yuvPixelFormats  = []string{"yuv420p", "yuv422p", "yuv444p", "yuv420p10le", "yuv422p10le", "yuv444p10le"}
+	bitratePattern   = regexp.MustCompile(`^\d+(\.\d+)?[kKmM]?$`)
+	encoderListOrder = []string{"libx264", "libx265", "libsvtav1", "libaom-av1", "libvpx-vp9", "ffv1"}
+)
+
+var encoderSpecs = map[string]encoderSpec{
+	"libx264": {
+		name:         "H.264 (x264)",
+		crfMax:       51,
+		defaultCRF:   constants.DefaultCRF,
+		presetFlag:   "-preset",
+		presets:      x26xPresets,
+		tuneFlag:     "-tune",
+		tunes:        []string{"film", "animation", "grain", "stillimage", "fastdecode", "zerolatency"},
+		pixelFormats: yuvPixelFormats,
+	},
+	"libx265": {
+		name:         "H.265 (x265)",
+		crfMax:       51,
+		defaultCRF:   20,
+		presetFlag:   "-preset",
+		presets:      x26xPresets,
+		tuneFlag:     "-tune",
+		tunes:        []string{"grain", "animation", "fastdecode", "zerolatency", "psnr", "ssim"},
+		pixelFormats: yuvPixelFormats,
+	},
+	"libsvtav1": {
+		name:         "AV1 (SVT-AV1)",
+		crfMax:       63,
+		defaultCRF:   30,
+		presetFlag:   "-preset",
+		presets:      numericRange(0, 13),
+		pixelFormats: []string{"yuv420p", "yuv420p10le"},
+	},
+	"libaom-av1": {
+		name:              "AV1 (libaom)",
+		crfMax:            63,
+		defaultCRF:        30,
+		presetFlag:        "-cpu-used",
+		presets:           numericRange(0, 8),
+		tuneFlag:          "-tune",
+		tunes:             []string{"psnr", "ssim"},
+		pixelFormats:      yuvPixelFormats,
+		zeroBitrateForCRF: true,
+	},
+	"libvpx-vp9": {
+		name:              "VP9 (libvpx)",
+		crfMax:            63,
+		defaultCRF:        31,
+		presetFlag:        "-deadline",
+		presets:           []string{"good", "best", "realtime"},
+		pixelFormats:      yuvPixelFormats,
+		zeroBitrateForCRF: true,
+	},
+	"ffv1": {
+		name:         "FFV1 (lossless)",
+		pixelFormats: slices.Concat(yuvPixelFormats, []string{"bgr0"}),
+		extraArgs:    []string{"-level", "3"},
+	},
+}
+
+func numericRange(from, to int) []string {
+	values := make([]string, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		values = append(values, strconv.Itoa(i))
+	}
+	return values
+}
+
+// encoderArgs returns the ffmpeg output options encoding the upscaled video.
+// The options are expected to be validated with validateEncoderOptions.
+func encoderArgs(options datatransfers.EncoderOptions) []string {
+	codec := options.Codec
+	if codec == "" {
+		codec = constants.DefaultVideoEncoder
+	}
+	spec := encoderSpecs[codec]
+
+	args := []string{"-c:v", codec}
+	switch {
+	case spec.crfMax == 0:
+		// lossless, no rate control
+	case options.Bitrate != "":
+		args = append(args, "-b:v", options.Bitrate)
+	default:
+		crf := spec.defaultCRF
+		if options.CRF != nil {
+			crf = *options.CRF
+		}
+		args = append(args, "-crf", strconv.Itoa(crf))
+		if spec.zeroBitrateForCRF {
+			args = append(args, "-b:v", "0")
+		}
+	}
+
+	if options.Preset != "" {
+		args = append(args, spec.presetFlag, options.Preset)
+	}
+	if options.Tune != "" {
+		args = append(args, spec.tuneFlag, options.Tune)
+	}
+
+	pixelFormat := options.PixelFormat
+	if pixelFormat == "" {
+		pixelFormat = spec.pixelFormats[0]
+	}
+	args = append(args, "-pix_fmt", pixelFormat)
+
+	return append(args, spec.extraArgs...)
+}
+
+// validateEncoderOptions checks the options against the encoder catalog.
+func validateEncoderOptions(options datatransfers.EncoderOptions) error {
+	codec := options.Codec
+	if codec == "" {
+		codec = constants.DefaultVideoEncoder
+	}
+	spec, ok := encoderSpecs[codec]
+	if !ok {
+		return fmt.Errorf("unsupported encoder %s, expected one of %s", codec, strings.Join(encoderListOrder, ", "))
+	}
+
+	if spec.crfMax == 0 {
+		if options.CRF != nil || options.Bitrate != "" {
+			return fmt.Errorf("%s is lossless, crf and bitrate are not supported", codec)
+		}
+	} else if options.CRF != nil && (*options.CRF < 0 || *options.CRF > spec.crfMax) {
+		return fmt.Errorf("crf of %s must be between 0 and %d", codec, spec.crfMax)
+	}
+	if options.Bitrate != "" && !bitratePattern.MatchString(options.Bitrate) {
+		return fmt.Errorf("invalid bitrate %q, expected e.g. 8M or 4500k", options.Bitrate)
+	}
+
+	if options.Preset != "" && !slices.Contains(spec.presets, options.Preset) {
+		if spec.presetFlag == "" {
+			return fmt.Errorf("%s has no presets", codec)
+		}
+		return fmt.Errorf("invalid preset %q for %s, expected one of %s", options.Preset, codec, strings.Join(spec.presets, ", "))
+	}
+	if options.Tune != "" && !slices.Contains(spec.tunes, options.Tune) {
+		if spec.tuneFlag == "" {
+			return fmt.Errorf("%s has no tune option", codec)
+		}
+		return fmt.Errorf("invalid tune %q for %s, expected one of %s", options.Tune, codec, strings.Join(spec.tunes, ", "))
+	}
+	if options.PixelFormat != "" && !slices.Contains(spec.pixelFormats, options.PixelFormat) {
+		return fmt.Errorf("invalid pixel format %q for %s, expected one of %s", options.PixelFormat, codec, strings.Join(spec.pixelFormats, ", "))
+	}
+	return nil
+}
+
+// ValidateEncoder checks the encoder options and that the ffmpeg in use was
+// built with the encoder.
+func (u *videoUpscalerUsecase) ValidateEncoder(ctx context.Context, options datatransfers.EncoderOptions) error {
+	if err := validateEncoderOptions(options); err != nil {
+		return err
+	}
+
+	available, err := u.availableEncoders(ctx)
+	if err != nil {
+		return err
+	}
+
+	codec := options.Codec
+	if codec == "" {
+		codec = constants.DefaultVideoEncoder
+	}
+	if !available[codec] {
+		return fmt.Errorf("encoder %s is not available in this ffmpeg build", codec)
+	}
+	return nil
+}
+
+// ListEncoders returns the supported encoders and whether ffmpeg has them.
+func (u *videoUpscalerUsecase) ListEncoders(ctx context.Context) ([]datatransfers.EncoderInfo, error) {
+	available, err := u.availableEncoders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	encoders := make([]datatransfers.EncoderInfo, 0, len(encoderListOrder))
+	for _, codec := range encoderListOrder {
+		spec := encoderSpecs[codec]
+		encoders = append(encoders, datatransfers.EncoderInfo{
+			Codec:        codec,
+			Name:         spec.name,
+			Available:    available[codec],
+			Lossless:     spec.crfMax == 0,
+			CRFMax:       spec.crfMax,
+			DefaultCRF:   spec.defaultCRF,
+			Presets:      spec.presets,
+			Tunes:        spec.tunes,
+			PixelFormats: spec.pixelFormats,
+		})
+	}
+	return encoders, nil
+}
+
+// availableEncoders lists the video encoders of `ffmpeg -encoders`, the result
+// is cached since the binary does not change while the app runs.
+func (u *videoUpscalerUsecase) availableEncoders(ctx context.Context) (map[string]bool, error) {
+	u.encodersMu.Lock()
+	defer u.encodersMu.Unlock()
+
+	if u.encoders != nil {
+		return u.encoders, nil
+	}
+
+	cmd := exec.CommandContext(ctx, config.Paths.FFmpegPath, "-hide_banner", "-encoders")
+	utils.HideWindowsCMD(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ffmpeg encoders: %v", err)
+	}
+
+	u.encoders = parseEncoders(output)
+	return u.encoders, nil
+}
+
+// parseEncoders reads the video encoder names from `ffmpeg -encoders`, listed
+// after the legend as " V....D libx264   libx264 H.264 / AVC ...".
+func parseEncoders(output []byte) map[string]bool {
+	encoders := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	inList := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "---") {
+			inList = true
+			continue
+		}
+		if inList && len(fields) >= 2 && strings.HasPrefix(fields[0], "V") {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
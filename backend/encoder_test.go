@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+// intPtr returns a pointer to value, for the CRF of encoder options.
+func intPtr(value int) *int {
+	return &value
+}
+
+func TestValidateEncoderOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options datatransfers.EncoderOptions
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "x265 tuned", options: datatransfers.EncoderOptions{Codec: "libx265", CRF: intPtr(28), Preset: "slow", Tune: "grain", PixelFormat: "yuv420p10le"}},
+		{name: "bitrate", options: datatransfers.EncoderOptions{Codec: "libvpx-vp9", Bitrate: "4500k"}},
+		{name: "lossless", options: datatransfers.EncoderOptions{Codec: "ffv1", PixelFormat: "bgr0"}},
+		{name: "unknown encoder", options: datatransfers.EncoderOptions{Codec: "mpeg2video"}, wantErr: true},
+		{name: "crf zero", options: datatransfers.EncoderOptions{Codec: "libx264", CRF: intPtr(0)}},
+		{name: "crf too high", options: datatransfers.EncoderOptions{Codec: "libx264", CRF: intPtr(52)}, wantErr: true},
+		{name: "negative crf", options: datatransfers.EncoderOptions{Codec: "libx264", CRF: intPtr(-1)}, wantErr: true},
+		{name: "crf of a lossless encoder", options: datatransfers.EncoderOptions{Codec: "ffv1", CRF: intPtr(10)}, wantErr: true},
+		{name: "crf zero of a lossless encoder", options: datatransfers.EncoderOptions{Codec: "ffv1", CRF: intPtr(0)}, wantErr: true},
+		{name: "invalid bitrate", options: datatransfers.EncoderOptions{Bitrate: "fast"}, wantErr: true},
+		{name: "invalid preset", options: datatransfers.EncoderOptions{Preset: "turbo"}, wantErr: true},
+		{name: "no tune option", options: datatransfers.EncoderOptions{Codec: "libsvtav1", Tune: "film"}, wantErr: true},
+		{name: "invalid pixel format", options: datatransfers.EncoderOptions{PixelFormat: "rgb24"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateEncoderOptions(tt.options); (err != nil) != tt.wantErr {
+				t.Errorf("validateEncoderOptions() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncoderArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		options datatransfers.EncoderOptions
+		want    []string
+	}{
+		{
+			name: "encoder default crf",
+			want: []string{"-c:v", "libx264", "-crf", "18", "-pix_fmt", "yuv420p"},
+		},
+		{
+			name:    "crf zero is lossless",
+			options: datatransfers.EncoderOptions{Codec: "libx264", CRF: intPtr(0), Preset: "veryslow"},
+			want:    []string{"-c:v", "libx264", "-crf", "0", "-preset", "veryslow", "-pix_fmt", "yuv420p"},
+		},
+		{
+			name:    "bitrate replaces crf",
+			options: datatransfers.EncoderOptions{Codec: "libx264", CRF: intPtr(20), Bitrate: "8M"},
+			want:    []string{"-c:v", "libx264", "-b:v", "8M", "-pix_fmt", "yuv420p"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encoderArgs(tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encoderArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEncoders(t *testing.T) {
+	output := []byte(`Encoders:
+ V..... = Video
+ A..... = Audio
+ S..... = Subtitle
+ .F.... = Frame-level multithreading
+ ------
+ V....D ffv1                 FFmpeg video codec #1
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ VF...D libvpx-vp9           libvpx VP9 (codec vp9)
+ A....D aac                  AAC (Advanced Audio Coding)
+ S..... srt                  SubRip subtitle
+`)
+
+	want := map[string]bool{"ffv1": true, "libx264": true, "libvpx-vp9": true}
+	if got := parseEncoders(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEncoders() = %v, want %v", got, want)
+	}
+}
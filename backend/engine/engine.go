@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"context"
+)
+
+// Capabilities describes what an upscale engine is able to do.
+type Capabilities struct {
+	Name              string
+	Models            []string
+	Scales            []int
+	SupportsDirectory bool // engine can upscale a whole directory in one call
+	RequiresGPU       bool
+}
+
+// Options holds the per-call parameters passed to an engine.
+type Options struct {
+	Model    string
+	Scale    int
+	TileSize int // 0 = let the engine decide
+}
+
+// UpscaleEngine is implemented by every backend able to upscale video frames.
+type UpscaleEngine interface {
+	Capabilities() Capabilities
+	// Probe returns an error when the engine cannot run on this machine.
+	Probe(ctx context.Context) error
+	UpscaleFrame(ctx context.Context, inputPath, outputPath string, opts Options) error
+	UpscaleDir(ctx context.Context, inputDir, outputDir string, opts Options) error
+}
+
+// ModelScaler is implemented by engines whose models only run at some of the
+// engine scales.
+type ModelScaler interface {
+	ModelScales(model string) []int
+}
+
+// ScalesFor returns the scales the engine supports with the given model.
+func ScalesFor(e UpscaleEngine, model string) []int {
+	if scaler, ok := e.(ModelScaler); ok {
+		return scaler.ModelScales(model)
+	}
+	return e.Capabilities().Scales
+}
+
+// SupportsModel reports whether the engine lists the given model.
+func SupportsModel(e UpscaleEngine, model string) bool {
+	for _, m := range e.Capabilities().Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FakeModel is the only model understood by the fake engine.
+const FakeModel = "fake-nearest"
+
+type fakeEngine struct{}
+
+// NewFake returns a deterministic pure-Go engine doing nearest-neighbour
+// scaling. It needs no GPU or external binary, which makes it suitable for
+// exercising the pipeline on machines without Vulkan.
+func NewFake() UpscaleEngine {
+	return &fakeEngine{}
+}
+
+func (e *fakeEngine) Capabilities() Capabilities {
+	return Capabilities{
+		Name:              "fake",
+		Models:            []string{FakeModel},
+		Scales:            []int{1, 2, 3, 4},
+		SupportsDirectory: true,
+		RequiresGPU:       false,
+	}
+}
+
+// Probe always succeeds, the fake engine has no external dependency.
+func (e *fakeEngine) Probe(ctx context.Context) error {
+	return nil
+}
+
+// UpscaleFrame scales a png file by opts.Scale using nearest-neighbour sampling.
+func (e *fakeEngine) UpscaleFrame(ctx context.Context, inputPath, outputPath string, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	src, err := png.Decode(in)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %v", inputPath, err)
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return png.Encode(out, scaleNearest(src, opts.Scale))
+}
+
+// UpscaleDir scales every png in inputDir into outputDir, keeping file names.
+func (e *fakeEngine) UpscaleDir(ctx context.Context, inputDir, outputDir string, opts Options) error {
+	frames, err := filepath.Glob(filepath.Join(inputDir, "*.png"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(frames)
+
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, frame := range frames {
+		if err := e.UpscaleFrame(ctx, frame, filepath.Join(outputDir, filepath.Base(frame)), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpscaleStream scales rgb24 frames in memory using nearest-neighbour sampling.
+func (e *fakeEngine) UpscaleStream(ctx context.Context, in <-chan Frame, out chan<- Frame, width, height int, opts Options) error {
+	defer close(out)
+
+	scale := max(1, opts.Scale)
+	for {
+		select {
+		case frame, ok := <-in:
+			if !ok {
+				return nil
+			}
+
+			upscaled := Frame{Index: frame.Index, Width: frame.Width * scale, Height: frame.Height * scale}
+			upscaled.Pix = make([]byte, upscaled.Width*upscaled.Height*3)
+			for y := 0; y < upscaled.Height; y++ {
+				for x := 0; x < upscaled.Width; x++ {
+					src := ((y/scale)*frame.Width + x/scale) * 3
+					copy(upscaled.Pix[(y*upscaled.Width+x)*3:], frame.Pix[src:src+3])
+				}
+			}
+
+			select {
+			case out <- upscaled:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// writeProbeImage writes a small gradient png used to probe engines.
+func writeProbeImage(path string) error {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 16), B: 128, A: 255})
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, img)
+}
+
+func scaleNearest(src image.Image, scale int) *image.RGBA {
+	if scale < 1 {
+		scale = 1
+	}
+
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < dst.Bounds().Dy(); y++ {
+		for x := 0; x < dst.Bounds().Dx(); x++ {
+			dst.Set(x, y, src.At(b.Min.X+x/scale, b.Min.Y+y/scale))
+		}
+	}
+	return dst
+}
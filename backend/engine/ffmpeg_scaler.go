@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// Models understood by the ffmpeg scaler engine. The "-sharp" variants apply
+// an unsharp mask after resampling.
+const (
+	FFmpegModelLanczos      = "ffmpeg-lanczos"
+	FFmpegModelLanczosSharp = "ffmpeg-lanczos-sharp"
+	FFmpegModelSpline       = "ffmpeg-spline"
+	FFmpegModelSplineSharp  = "ffmpeg-spline-sharp"
+)
+
+// FFmpegModels lists every model of the ffmpeg scaler engine.
+var FFmpegModels = []string{
+	FFmpegModelLanczos,
+	FFmpegModelLanczosSharp,
+	FFmpegModelSpline,
+	FFmpegModelSplineSharp,
+}
+
+type ffmpegScalerEngine struct {
+	binaryPath func() string
+}
+
+// NewFFmpegScaler returns a CPU-only engine built on ffmpeg's scale filter.
+// It is used as a fallback when Real-ESRGAN cannot run (e.g. no Vulkan GPU).
+func NewFFmpegScaler() UpscaleEngine {
+	return &ffmpegScalerEngine{
+		binaryPath: func() string { return config.Paths.FFmpegPath },
+	}
+}
+
+func (e *ffmpegScalerEngine) Capabilities() Capabilities {
+	return Capabilities{
+		Name:              "ffmpeg-scale",
+		Models:            FFmpegModels,
+		Scales:            []int{1, 2, 3, 4},
+		SupportsDirectory: false,
+		RequiresGPU:       false,
+	}
+}
+
+// Probe checks that the ffmpeg binary can be executed.
+func (e *ffmpegScalerEngine) Probe(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, e.binaryPath(), "-hide_banner", "-version")
+	utils.HideWindowsCMD(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg is not usable: %v", err)
+	}
+	return nil
+}
+
+// FFmpegScaleFilter returns the -vf expression used to upscale by scale with the given model.
+func FFmpegScaleFilter(model string, scale int) string {
+	flags := "lanczos"
+	sharpen := false
+
+	switch model {
+	case FFmpegModelLanczosSharp:
+		sharpen = true
+	case FFmpegModelSpline:
+		flags = "spline"
+	case FFmpegModelSplineSharp:
+		flags = "spline"
+		sharpen = true
+	}
+
+	filter := fmt.Sprintf("scale=iw*%d:ih*%d:flags=%s", scale, scale, flags)
+	if sharpen {
+		filter += ",unsharp=5:5:0.8:5:5:0.0"
+	}
+	return filter
+}
+
+// UpscaleFrame upscales a single image file.
+func (e *ffmpegScalerEngine) UpscaleFrame(ctx context.Context, inputPath, outputPath string, opts Options) error {
+	cmd := exec.CommandContext(ctx, e.binaryPath(),
+		"-hide_banner", "-loglevel", "error",
+		"-i", inputPath,
+		"-vf", FFmpegScaleFilter(opts.Model, opts.Scale),
+		"-y", outputPath,
+	)
+	utils.HideWindowsCMD(cmd)
+	return cmd.Run()
+}
+
+// UpscaleStream pipes rgb24 frames through a single long-lived ffmpeg process.
+func (e *ffmpegScalerEngine) UpscaleStream(ctx context.Context, in <-chan Frame, out chan<- Frame, width, height int, opts Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.binaryPath(),
+		"-hide_banner", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgb24", "-s", fmt.Sprintf("%dx%d", width, height),
+		"-i", "-",
+		"-vf", FFmpegScaleFilter(opts.Model, opts.Scale),
+		"-f", "rawvideo", "-pix_fmt", "rgb24",
+		"-",
+	)
+	utils.HideWindowsCMD(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		close(out)
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		close(out)
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		close(out)
+		return err
+	}
+
+	// Feed frames concurrently, ffmpeg buffers a few frames before producing output
+	writeErr := make(chan error, 1)
+	go func() {
+		err := WriteRawFrames(ctx, stdin, in, nil)
+		stdin.Close()
+		if err != nil {
+			cancel()
+		}
+		writeErr <- err
+	}()
+
+	readErr := ReadRawFrames(ctx, stdout, width*opts.Scale, height*opts.Scale, out)
+	if readErr != nil {
+		cancel()
+	}
+
+	if err := <-writeErr; err != nil {
+		cmd.Wait()
+		return fmt.Errorf("failed to feed ffmpeg scaler: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg scaler failed: %v", err)
+	}
+	return readErr
+}
+
+// UpscaleDir upscales every png in inputDir into outputDir.
+func (e *ffmpegScalerEngine) UpscaleDir(ctx context.Context, inputDir, outputDir string, opts Options) error {
+	frames, err := filepath.Glob(filepath.Join(inputDir, "*.png"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(frames)
+
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, frame := range frames {
+		if err := e.UpscaleFrame(ctx, frame, filepath.Join(outputDir, filepath.Base(frame)), opts); err != nil {
+			return fmt.Errorf("failed to upscale frame %s: %w", frame, err)
+		}
+	}
+	return nil
+}
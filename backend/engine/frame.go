@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Frame is a single decoded video frame in packed rgb24 layout.
+type Frame struct {
+	Index  int
+	Width  int
+	Height int
+	Pix    []byte
+}
+
+// StreamEngine is implemented by engines able to upscale in-memory frames,
+// used by the streaming pipeline that never writes frames to disk.
+type StreamEngine interface {
+	// UpscaleStream upscales every frame received on in and sends the result
+	// on out in the same order. It closes out before returning.
+	UpscaleStream(ctx context.Context, in <-chan Frame, out chan<- Frame, width, height int, opts Options) error
+}
+
+// ReadRawFrames reads rgb24 frames of the given size from r and sends them on
+// out until EOF. It closes out before returning.
+func ReadRawFrames(ctx context.Context, r io.Reader, width, height int, out chan<- Frame) error {
+	defer close(out)
+
+	frameSize := width * height * 3
+	for index := 0; ; index++ {
+		pix := make([]byte, frameSize)
+		if _, err := io.ReadFull(r, pix); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case out <- Frame{Index: index, Width: width, Height: height, Pix: pix}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// WriteRawFrames writes every frame received on in to w, calling onFrame after
+// each write when it is not nil.
+func WriteRawFrames(ctx context.Context, w io.Writer, in <-chan Frame, onFrame func(Frame)) error {
+	for {
+		select {
+		case frame, ok := <-in:
+			if !ok {
+				return nil
+			}
+			if _, err := w.Write(frame.Pix); err != nil {
+				return err
+			}
+			if onFrame != nil {
+				onFrame(frame)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
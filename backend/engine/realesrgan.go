@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// DefaultRealEsrganModel is the model used when none is picked, its files must
+// always be shipped.
+const DefaultRealEsrganModel = "realesr-animevideov3"
+
+// RealEsrganModels lists the models shipped with the embedded Real-ESRGAN binary.
+var RealEsrganModels = []string{
+	DefaultRealEsrganModel, // the binary appends -x<scale>
+	"realesr-animevideov3-x2",
+	"realesr-animevideov3-x3",
+	"realesr-animevideov3-x4",
+	"realesrgan-x4plus-anime",
+	"realesrgan-x4plus",
+	"realesrnet-x4plus",
+	"RealESRGANv2-animevideo-xsx2",
+	"RealESRGANv2-animevideo-xsx4",
+}
+
+// RealEsrganModelFiles returns the files a model needs in the models dir.
+func RealEsrganModelFiles(model string) []string {
+	names := []string{model}
+	if model == DefaultRealEsrganModel {
+		names = []string{model + "-x2", model + "-x3", model + "-x4"}
+	}
+
+	files := make([]string, 0, len(names)*2)
+	for _, name := range names {
+		files = append(files, name+".param", name+".bin")
+	}
+	return files
+}
+
+type realEsrganEngine struct {
+	binaryPath func() string
+	modelsDir  func() string
+}
+
+// NewRealEsrgan returns the realesrgan-ncnn-vulkan adapter. The binary path is
+// read from config.Paths at call time since it is only known after startup.
+func NewRealEsrgan() UpscaleEngine {
+	return &realEsrganEngine{
+		binaryPath: func() string { return config.Paths.RealEsrganPath },
+		modelsDir:  func() string { return config.Paths.ModelsDir },
+	}
+}
+
+func (e *realEsrganEngine) Capabilities() Capabilities {
+	return Capabilities{
+		Name:              "realesrgan-ncnn-vulkan",
+		Models:            RealEsrganModels,
+		Scales:            []int{2, 3, 4},
+		SupportsDirectory: true,
+		RequiresGPU:       true,
+	}
+}
+
+// ModelScales returns the scales a model was trained for. The default model
+// picks its -x2/-x3/-x4 variant from the scale, the others have a fixed one.
+func (e *realEsrganEngine) ModelScales(model string) []int {
+	if model != DefaultRealEsrganModel {
+		for _, scale := range []int{2, 3, 4} {
+			if strings.HasSuffix(model, fmt.Sprintf("x%d", scale)) || strings.Contains(model, fmt.Sprintf("x%dplus", scale)) {
+				return []int{scale}
+			}
+		}
+	}
+	return e.Capabilities().Scales
+}
+
+func (e *realEsrganEngine) args(input, output string, opts Options) []string {
+	args := []string{
+		"-i", input,
+		"-o", output,
+		"-s", fmt.Sprintf("%d", opts.Scale),
+		"-t", fmt.Sprintf("%d", opts.TileSize), /* tile size (>=32/0=auto, default=0) can be 0,0,0 for multi-gpu */
+		"-n", opts.Model,
+		"-g", "0", /* gpu device to use (default=auto) can be 0,1,2 for multi-gpu */
+		"-j", "4:4:4", /* thread count for load/proc/save (default=1:2:2) can be 1:2,2,2:2 for multi-gpu */
+	}
+	// Without -m the binary looks for a models dir next to itself, which is
+	// not where they are when it comes from $PATH
+	if modelsDir := e.modelsDir(); modelsDir != "" {
+		args = append(args, "-m", modelsDir)
+	}
+	return args
+}
+
+// Probe upscales a tiny generated image to make sure the binary and the
+// Vulkan device actually work, the binary alone starts fine without a GPU.
+func (e *realEsrganEngine) Probe(ctx context.Context) error {
+	if e.binaryPath() == "" {
+		return fmt.Errorf("realesrgan-ncnn-vulkan was not found")
+	}
+
+	probeDir, err := os.MkdirTemp("", "realesrgan-probe-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(probeDir)
+
+	inputPath := filepath.Join(probeDir, "probe.png")
+	outputPath := filepath.Join(probeDir, "probe_out.png")
+	if err := writeProbeImage(inputPath); err != nil {
+		return err
+	}
+
+	err = e.UpscaleFrame(ctx, inputPath, outputPath, Options{Model: RealEsrganModels[0], Scale: 2})
+	if err != nil {
+		return fmt.Errorf("realesrgan-ncnn-vulkan is not usable: %v", err)
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		return fmt.Errorf("realesrgan-ncnn-vulkan produced no output, is a Vulkan device available?")
+	}
+	return nil
+}
+
+// UpscaleFrame upscales a single image file.
+func (e *realEsrganEngine) UpscaleFrame(ctx context.Context, inputPath, outputPath string, opts Options) error {
+	cmd := exec.CommandContext(ctx, e.binaryPath(), e.args(inputPath, outputPath, opts)...)
+	utils.HideWindowsCMD(cmd)
+	return cmd.Run()
+}
+
+// UpscaleDir upscales every image in inputDir into outputDir with a single
+// process, so the model and the Vulkan device are initialised only once.
+func (e *realEsrganEngine) UpscaleDir(ctx context.Context, inputDir, outputDir string, opts Options) error {
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	args := append(e.args(inputDir, outputDir, opts), "-f", "png")
+	cmd := exec.CommandContext(ctx, e.binaryPath(), args...)
+	utils.HideWindowsCMD(cmd)
+	return cmd.Run()
+}
@@ -0,0 +1,261 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+const defaultWatchPollInterval = 5 * time.Second
+
+var watchedVideoExtensions = map[string]bool{
+	".mp4":  true,
+	".mkv":  true,
+	".avi":  true,
+	".mov":  true,
+	".webm": true,
+	".m4v":  true,
+}
+
+// fileSnapshot is what a poll saw of a file, a file is considered fully written
+// once two consecutive polls see the same size and modification time.
+type fileSnapshot struct {
+	size    int64
+	modTime time.Time
+}
+
+// FolderWatcher polls a folder for new videos and submits them to the job
+// manager with the configured model and scale. Once a job is finished the
+// original is moved to the processed or failed subfolder.
+type FolderWatcher struct {
+	logger     *utils.CustomLogger
+	jobManager *JobManager
+	jobsFolder string
+	config     datatransfers.WatchFolderConfig
+	prepare    PrepareRequestFunc
+	interval   time.Duration
+
+	mu        sync.Mutex
+	snapshots map[string]fileSnapshot
+	inFlight  map[string]string // file path -> job ID
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewFolderWatcher(logger *utils.CustomLogger, jobManager *JobManager, jobsFolder string, config datatransfers.WatchFolderConfig, prepare PrepareRequestFunc) (*FolderWatcher, error) {
+	if config.Preset == "" && (config.Model == "" || config.Scale <= 0) {
+		return nil, fmt.Errorf("a preset or a model and scale are required")
+	}
+
+	folder, err := filepath.Abs(config.Folder)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := os.Stat(folder)
+	if err != nil {
+		return nil, fmt.Errorf("watch folder not found: %v", err)
+	}
+	if !fileInfo.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", folder)
+	}
+	config.Folder = folder
+
+	if config.OutputFolder == "" {
+		return nil, fmt.Errorf("an output folder is required")
+	}
+	if err := os.MkdirAll(config.OutputFolder, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create output folder: %v", err)
+	}
+
+	for _, name := range []string{constants.WatchFolderProcessedName, constants.WatchFolderFailedName} {
+		if err := os.MkdirAll(filepath.Join(folder, name), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create %s folder: %v", name, err)
+		}
+	}
+
+	interval := defaultWatchPollInterval
+	if config.PollIntervalSeconds > 0 {
+		interval = time.Duration(config.PollIntervalSeconds) * time.Second
+	}
+
+	return &FolderWatcher{
+		logger:     logger,
+		jobManager: jobManager,
+		jobsFolder: jobsFolder,
+		config:     config,
+		prepare:    prepare,
+		interval:   interval,
+		snapshots:  make(map[string]fileSnapshot),
+		inFlight:   make(map[string]string),
+	}, nil
+}
+
+// Config returns the configuration the watcher runs with, defaults applied.
+func (w *FolderWatcher) Config() datatransfers.WatchFolderConfig {
+	return w.config
+}
+
+// Start polls the folder in the background until Stop is called or ctx is done.
+func (w *FolderWatcher) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		if w.config.Preset != "" {
+			w.logger.Info(fmt.Sprintf("👀 Watching %s (preset: %s)", w.config.Folder, w.config.Preset))
+		} else {
+			w.logger.Info(fmt.Sprintf("👀 Watching %s (model: %s, scale: %dx)", w.config.Folder, w.config.Model, w.config.Scale))
+		}
+		for {
+			w.poll(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling. Jobs already submitted keep running, their originals are
+// still moved once they finish unless they get cancelled.
+func (w *FolderWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// Wait blocks until the watcher is stopped and every submitted job is finished.
+func (w *FolderWatcher) Wait() {
+	w.wg.Wait()
+}
+
+func (w *FolderWatcher) poll(ctx context.Context) {
+	entries, err := os.ReadDir(w.config.Folder)
+	if err != nil {
+		w.logger.Error(fmt.Sprintf("failed to read watch folder: %v", err))
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	present := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !watchedVideoExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+
+		path := filepath.Join(w.config.Folder, entry.Name())
+		present[path] = true
+		if _, ok := w.inFlight[path]; ok {
+			continue
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshot := fileSnapshot{size: fileInfo.Size(), modTime: fileInfo.ModTime()}
+		previous, seen := w.snapshots[path]
+		w.snapshots[path] = snapshot
+		if !seen || previous != snapshot || snapshot.size == 0 || !isFileReadable(path) {
+			continue // still being written
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		w.submitLocked(ctx, path)
+	}
+
+	// Forget files that disappeared before they were picked up
+	for path := range w.snapshots {
+		if !present[path] {
+			delete(w.snapshots, path)
+		}
+	}
+}
+
+func (w *FolderWatcher) submitLocked(ctx context.Context, path string) {
+	request, err := NewFileRequest(path, w.jobsFolder, w.config.OutputFolder)
+	if err != nil {
+		w.logger.Error(fmt.Sprintf("failed to queue %s: %v", path, err))
+		w.moveOriginal(path, constants.WatchFolderFailedName)
+		return
+	}
+	request.Model = w.config.Model
+	request.ScaleMultiplier = w.config.Scale
+	request.Streaming = w.config.Streaming
+	if w.prepare != nil {
+		if err := w.prepare(request, w.config.Preset); err != nil {
+			w.logger.Error(fmt.Sprintf("failed to queue %s: %v", path, err))
+			os.RemoveAll(request.TempDir)
+			w.moveOriginal(path, constants.WatchFolderFailedName)
+			return
+		}
+	}
+
+	jobID := w.jobManager.Submit(request)
+	w.inFlight[path] = jobID
+	delete(w.snapshots, path)
+	w.logger.Info(fmt.Sprintf("📥 Queued %s from watch folder (job %s)", filepath.Base(path), jobID))
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		info, err := w.jobManager.Wait(jobID)
+		switch {
+		case err == nil && info.State == constants.JobStateDone:
+			w.moveOriginal(path, constants.WatchFolderProcessedName)
+		case info.State == constants.JobStateCancelled && ctx.Err() != nil:
+			// Cancelled by a shutdown, the file is picked up again next time
+		default:
+			w.logger.Error(fmt.Sprintf("watch folder job for %s %s: %s", filepath.Base(path), info.State, info.Error))
+			w.moveOriginal(path, constants.WatchFolderFailedName)
+		}
+
+		w.mu.Lock()
+		delete(w.inFlight, path)
+		w.mu.Unlock()
+	}()
+}
+
+// moveOriginal moves a watched file into the given subfolder, prefixing it with
+// a timestamp when a file with the same name is already there.
+func (w *FolderWatcher) moveOriginal(path, subfolder string) {
+	target := filepath.Join(w.config.Folder, subfolder, filepath.Base(path))
+	if _, err := os.Stat(target); err == nil {
+		target = filepath.Join(w.config.Folder, subfolder, fmt.Sprintf("%d_%s", utils.NowUnix(), filepath.Base(path)))
+	}
+
+	if err := os.Rename(path, target); err != nil {
+		w.logger.Error(fmt.Sprintf("failed to move %s to %s: %v", path, subfolder, err))
+	}
+}
+
+// isFileReadable reports whether the file can be opened, on Windows a file still
+// being copied may be locked by the writer.
+func isFileReadable(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return true
+}
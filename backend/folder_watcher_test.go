@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+func TestFolderWatcherMovesOriginals(t *testing.T) {
+	manager, _ := startJobManager(t, func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+		if params.InputFullFileName == "broken.mp4" {
+			return errors.New("no video stream")
+		}
+		return nil
+	})
+
+	folder := t.TempDir()
+	for _, name := range []string{"good.mp4", "broken.mp4"} {
+		if err := os.WriteFile(filepath.Join(folder, name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watcher, err := NewFolderWatcher(newTestLogger(t), manager, t.TempDir(), datatransfers.WatchFolderConfig{
+		Folder:       folder,
+		Model:        "realesr-animevideov3",
+		Scale:        2,
+		OutputFolder: t.TempDir(),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first poll records the files, the second sees them unchanged and queues them
+	ctx := context.Background()
+	watcher.poll(ctx)
+	watcher.poll(ctx)
+	watcher.Wait()
+
+	for name, subfolder := range map[string]string{
+		"good.mp4":   constants.WatchFolderProcessedName,
+		"broken.mp4": constants.WatchFolderFailedName,
+	} {
+		if _, err := os.Stat(filepath.Join(folder, subfolder, name)); err != nil {
+			t.Errorf("%s was not moved to %s: %v", name, subfolder, err)
+		}
+		if _, err := os.Stat(filepath.Join(folder, name)); !os.IsNotExist(err) {
+			t.Errorf("%s is still in the watch folder", name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/models"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// countFrames returns the number of video frames and how it was found, trying
+// in order the container header, duration x frame rate, counting the packets
+// and decoding the whole video. The estimate is skipped for a variable frame
+// rate, the average rate can be far off. Zero frames is an error.
+func (u *videoUpscalerUsecase) countFrames(ctx context.Context, inputPath string, probe *models.FFProbeOutput) (int, constants.FrameCountMethod, error) {
+	stream := probe.Streams[0]
+
+	// MKV and WebM headers have no frame count
+	if frames, _ := strconv.Atoi(stream.NbFrames); frames > 0 {
+		return frames, constants.FrameCountHeader, nil
+	}
+
+	duration, _ := strconv.ParseFloat(stream.Duration, 64)
+	if duration <= 0 {
+		duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	}
+	avgFrameRate, rFrameRate := parseRational(stream.AvgFrameRate), parseRational(stream.RFrameRate)
+	frameRate := rationalFloat(avgFrameRate)
+	if frameRate == 0 {
+		frameRate = rationalFloat(rFrameRate)
+	}
+	if isVariableFrameRate(rFrameRate, avgFrameRate) {
+		u.logger.Warning("⚠️ Video has no frame count and a variable frame rate, counting packets")
+	} else if frames := int(math.Ceil(duration*frameRate - 0.001)); frames > 0 {
+		return frames, constants.FrameCountEstimate, nil
+	} else {
+		u.logger.Warning("⚠️ Video has no frame count nor duration, counting packets")
+	}
+	frames, err := u.probeStreamCount(ctx, inputPath, "-count_packets", "nb_read_packets")
+	if err == nil && frames > 0 {
+		return frames, constants.FrameCountPackets, nil
+	}
+
+	u.logger.Warning("⚠️ Counting packets failed, decoding the whole video to count frames")
+	frames, err = u.probeStreamCount(ctx, inputPath, "-count_frames", "nb_read_frames")
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to count frames: %v", err)
+	}
+	if frames <= 0 {
+		return 0, "", fmt.Errorf("no video frames found in %s", inputPath)
+	}
+	return frames, constants.FrameCountDecode, nil
+}
+
+// probeStreamCount runs ffprobe with a counting flag and returns the count
+// reported in entry for the first video stream.
+func (u *videoUpscalerUsecase) probeStreamCount(ctx context.Context, inputPath, flag, entry string) (int, error) {
+	cmd := exec.CommandContext(ctx, config.Paths.FFprobePath, "-v", "error", "-select_streams", "v:0", flag,
+		"-show_entries", "stream="+entry, "-of", "json", inputPath)
+	utils.HideWindowsCMD(cmd)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+
+	var probe struct {
+		Streams []map[string]string `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, err
+	}
+	if len(probe.Streams) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(probe.Streams[0][entry])
+}
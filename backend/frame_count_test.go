@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/models"
+)
+
+// fakeFFprobe points the config at a script printing packets as the packet
+// count of the first video stream.
+func fakeFFprobe(t *testing.T, packets string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "ffprobe")
+	script := "#!/bin/sh\necho '{\"streams\":[{\"nb_read_packets\":\"" + packets + "\"}]}'\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.Paths.FFprobePath
+	config.Paths.FFprobePath = path
+	t.Cleanup(func() { config.Paths.FFprobePath = previous })
+}
+
+func TestCountFrames(t *testing.T) {
+	tests := []struct {
+		name       string
+		probe      string
+		wantFrames int
+		wantMethod constants.FrameCountMethod
+	}{
+		{
+			name:       "header",
+			probe:      `{"streams":[{"nb_frames":"240","r_frame_rate":"24/1","avg_frame_rate":"24/1"}]}`,
+			wantFrames: 240, wantMethod: constants.FrameCountHeader,
+		},
+		{
+			name:       "constant rate estimate",
+			probe:      `{"streams":[{"r_frame_rate":"25/1","avg_frame_rate":"25/1","duration":"4.000000"}]}`,
+			wantFrames: 100, wantMethod: constants.FrameCountEstimate,
+		},
+		{
+			name:       "variable rate counts packets",
+			probe:      `{"streams":[{"r_frame_rate":"60/1","avg_frame_rate":"20/1","duration":"4.000000"}]}`,
+			wantFrames: 123, wantMethod: constants.FrameCountPackets,
+		},
+		{
+			name:       "no duration counts packets",
+			probe:      `{"streams":[{"r_frame_rate":"25/1","avg_frame_rate":"25/1"}]}`,
+			wantFrames: 123, wantMethod: constants.FrameCountPackets,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeFFprobe(t, "123")
+			var probe models.FFProbeOutput
+			if err := json.Unmarshal([]byte(tt.probe), &probe); err != nil {
+				t.Fatal(err)
+			}
+
+			u := &videoUpscalerUsecase{logger: newTestLogger(t)}
+			frames, method, err := u.countFrames(context.Background(), "input.mkv", &probe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if frames != tt.wantFrames || method != tt.wantMethod {
+				t.Errorf("countFrames = %d (%s), want %d (%s)", frames, method, tt.wantFrames, tt.wantMethod)
+			}
+		})
+	}
+}
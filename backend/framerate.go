@@ -0,0 +1,207 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// vfrTolerance is how far avg_frame_rate may be from r_frame_rate before a video
+// counts as variable frame rate, containers round the average a little.
+const vfrTolerance = 0.001
+
+// frameListFileName is the ffconcat list timing the upscaled frames of a batch in vfr mode.
+const frameListFileName = "frames.ffconcat"
+
+// parseRational parses an ffprobe rate such as "30000/1001", unknown rates ("0/0") give zero.
+func parseRational(value string) datatransfers.Rational {
+	numerator, denominator, ok := strings.Cut(value, "/")
+	if !ok {
+		denominator = "1"
+	}
+	num, err := strconv.Atoi(numerator)
+	if err != nil || num <= 0 {
+		return datatransfers.Rational{}
+	}
+	den, err := strconv.Atoi(denominator)
+	if err != nil || den <= 0 {
+		return datatransfers.Rational{}
+	}
+	return datatransfers.Rational{Num: num, Den: den}
+}
+
+func rationalFloat(rate datatransfers.Rational) float64 {
+	if rate.Den == 0 {
+		return 0
+	}
+	return float64(rate.Num) / float64(rate.Den)
+}
+
+// rationalString formats a rate for ffmpeg options, e.g. -framerate 24000/1001.
+func rationalString(rate datatransfers.Rational) string {
+	return fmt.Sprintf("%d/%d", rate.Num, rate.Den)
+}
+
+func isVariableFrameRate(rate, avgRate datatransfers.Rational) bool {
+	r, avg := rationalFloat(rate), rationalFloat(avgRate)
+	if r == 0 || avg == 0 {
+		return false
+	}
+	return math.Abs(r-avg)/r > vfrTolerance
+}
+
+// resolveFrameRate fills the output frame rate and mode of the request from the
+// source. A forced VideoFPS always gives a constant frame rate, vfr mode probes
+// the timestamp of every source frame into videoMetadata.
+func (u *videoUpscalerUsecase) resolveFrameRate(ctx context.Context, params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) error {
+	switch {
+	case params.VideoFPS > 0:
+		params.FrameRate = datatransfers.Rational{Num: params.VideoFPS, Den: 1}
+		params.FrameRateMode = constants.FrameRateModeCFR
+	case params.FrameRateMode == "" || params.FrameRateMode == constants.FrameRateModeAuto:
+		params.FrameRateMode = constants.FrameRateModeCFR
+		if videoMetadata.IsVFR {
+			params.FrameRateMode = constants.FrameRateModeVFR
+		}
+	case params.FrameRateMode != constants.FrameRateModeCFR && params.FrameRateMode != constants.FrameRateModeVFR:
+		return fmt.Errorf("unsupported frame rate mode %s, expected auto, cfr or vfr", params.FrameRateMode)
+	}
+
+	if params.FrameRate.Num == 0 {
+		// A constant rate keeps the duration of a variable one when it is the average
+		params.FrameRate = videoMetadata.FrameRate
+		if videoMetadata.IsVFR || params.FrameRate.Num == 0 {
+			params.FrameRate = videoMetadata.AvgFrameRate
+		}
+	}
+	if params.FrameRate.Num == 0 {
+		return fmt.Errorf("the frame rate of the video is unknown, set a fixed fps")
+	}
+
+	if params.FrameRateMode == constants.FrameRateModeVFR {
+		timestamps, err := u.probeFrameTimestamps(ctx, params.TempFilePath)
+		if err != nil || len(timestamps) == 0 {
+			u.logger.Warning(fmt.Sprintf("⚠️ Failed to read the frame timestamps, using a constant %s fps: %v", rationalString(params.FrameRate), err))
+			params.FrameRateMode = constants.FrameRateModeCFR
+			return nil
+		}
+		videoMetadata.FrameTimestamps = timestamps
+	}
+
+	u.logger.Info(fmt.Sprintf("🎞️ Frame rate: %s (%.3f fps), mode: %s", rationalString(params.FrameRate), rationalFloat(params.FrameRate), params.FrameRateMode))
+	return nil
+}
+
+// probeFrameTimestamps returns the presentation time of every video frame in
+// seconds from the first one. Only packets are read, nothing is decoded.
+func (u *videoUpscalerUsecase) probeFrameTimestamps(ctx context.Context, videoPath string) ([]float64, error) {
+	cmd := exec.CommandContext(ctx, config.Paths.FFprobePath, "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "packet=pts_time", "-of", "csv=p=0", videoPath)
+	utils.HideWindowsCMD(cmd)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var timestamps []float64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		timestamp, err := strconv.ParseFloat(strings.Trim(strings.TrimSpace(scanner.Text()), ","), 64)
+		if err != nil {
+			continue // N/A
+		}
+		timestamps = append(timestamps, timestamp)
+	}
+
+	// Packets come in decode order, B-frames make it differ from presentation order
+	sort.Float64s(timestamps)
+	for i := len(timestamps) - 1; i >= 0; i-- {
+		timestamps[i] -= timestamps[0]
+	}
+	return timestamps, scanner.Err()
+}
+
+// frameTimestamp returns the time of frame index, frames past the probed ones
+// are extrapolated at frameRate.
+func frameTimestamp(timestamps []float64, index int, frameRate float64) float64 {
+	if index < len(timestamps) {
+		return timestamps[index]
+	}
+	if len(timestamps) == 0 {
+		return float64(index) / frameRate
+	}
+	lastIndex := len(timestamps) - 1
+	return timestamps[lastIndex] + float64(index-lastIndex)/frameRate
+}
+
+// startOffset returns how far the first video frame is from the start of the
+// file, ffprobe start_time values of the stream and of the format. -ss counts
+// from the start of the file, the earliest stream.
+func startOffset(streamStartTime, formatStartTime string) float64 {
+	streamStart, err := strconv.ParseFloat(streamStartTime, 64)
+	if err != nil {
+		return 0
+	}
+	formatStart, err := strconv.ParseFloat(formatStartTime, 64)
+	if err != nil || streamStart <= formatStart {
+		return 0
+	}
+	return streamStart - formatStart
+}
+
+// seekPosition returns the -ss timestamp of startFrame. It points half a frame
+// early so rounding never skips the target frame, accurate seeking then drops
+// every frame decoded before it. It reports false when the frame can't be timed.
+func seekPosition(startFrame int, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) (string, bool) {
+	if startFrame < len(videoMetadata.FrameTimestamps) {
+		timestamps := videoMetadata.FrameTimestamps
+		return fmt.Sprintf("%.6f", videoMetadata.StartOffset+(timestamps[startFrame-1]+timestamps[startFrame])/2), true
+	}
+
+	frameRate := rationalFloat(videoMetadata.AvgFrameRate)
+	if !videoMetadata.IsVFR || frameRate == 0 {
+		frameRate = rationalFloat(videoMetadata.FrameRate)
+	}
+	if frameRate == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%.6f", videoMetadata.StartOffset+(float64(startFrame)-0.5)/frameRate), true
+}
+
+// writeFrameList writes the ffconcat list giving every upscaled frame of a batch
+// starting at startFrame the duration it has in the source.
+func writeFrameList(upscaledDir string, startFrame int, params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) error {
+	frames, err := filepath.Glob(filepath.Join(upscaledDir, "*.png"))
+	if err != nil || len(frames) == 0 {
+		return fmt.Errorf("no upscaled frames found in %s", upscaledDir)
+	}
+	sort.Strings(frames)
+
+	frameRate := rationalFloat(params.FrameRate)
+	var sb strings.Builder
+	sb.WriteString("ffconcat version 1.0\n")
+	for i, frame := range frames {
+		// Durations between rounded timestamps add up without drifting
+		start := math.Round(frameTimestamp(videoMetadata.FrameTimestamps, startFrame+i, frameRate)*1e6) / 1e6
+		end := math.Round(frameTimestamp(videoMetadata.FrameTimestamps, startFrame+i+1, frameRate)*1e6) / 1e6
+		fmt.Fprintf(&sb, "file '%s'\nduration %.6f\n", filepath.Base(frame), end-start)
+	}
+	// The concat demuxer ignores the duration of the last entry unless the file is repeated
+	fmt.Fprintf(&sb, "file '%s'\n", filepath.Base(frames[len(frames)-1]))
+
+	return os.WriteFile(filepath.Join(upscaledDir, frameListFileName), []byte(sb.String()), 0644)
+}
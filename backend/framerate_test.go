@@ -0,0 +1,70 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+func TestParseRational(t *testing.T) {
+	tests := []struct {
+		value string
+		want  datatransfers.Rational
+	}{
+		{value: "30000/1001", want: datatransfers.Rational{Num: 30000, Den: 1001}},
+		{value: "25/1", want: datatransfers.Rational{Num: 25, Den: 1}},
+		{value: "25", want: datatransfers.Rational{Num: 25, Den: 1}},
+		{value: "0/0"},
+		{value: "25/0"},
+		{value: "-25/1"},
+		{value: "N/A"},
+		{value: ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseRational(tt.value); got != tt.want {
+			t.Errorf("parseRational(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSeekPosition(t *testing.T) {
+	tests := []struct {
+		name          string
+		startFrame    int
+		videoMetadata datatransfers.FFProbeStreamsMetadataResponse
+		want          string
+		wantOK        bool
+	}{
+		{
+			name:       "constant rate",
+			startFrame: 10, videoMetadata: datatransfers.FFProbeStreamsMetadataResponse{FrameRate: datatransfers.Rational{Num: 25, Den: 1}},
+			want: "0.380000", wantOK: true,
+		},
+		{
+			name:       "first frame after the file start",
+			startFrame: 10, videoMetadata: datatransfers.FFProbeStreamsMetadataResponse{FrameRate: datatransfers.Rational{Num: 25, Den: 1}, StartOffset: 0.3},
+			want: "0.680000", wantOK: true,
+		},
+		{
+			name:       "frame timestamps",
+			startFrame: 2, videoMetadata: datatransfers.FFProbeStreamsMetadataResponse{FrameTimestamps: []float64{0, 0.1, 0.3, 0.6}, StartOffset: 1},
+			want: "1.200000", wantOK: true,
+		},
+		{
+			name:       "variable rate past the timestamps",
+			startFrame: 10, videoMetadata: datatransfers.FFProbeStreamsMetadataResponse{FrameRate: datatransfers.Rational{Num: 60, Den: 1}, AvgFrameRate: datatransfers.Rational{Num: 20, Den: 1}, IsVFR: true},
+			want: "0.475000", wantOK: true,
+		},
+		{name: "unknown rate", startFrame: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := seekPosition(tt.startFrame, &tt.videoMetadata)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("seekPosition(%d) = %q, %v, want %q, %v", tt.startFrame, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
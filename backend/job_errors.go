@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"errors"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+)
+
+// Failure kinds of an upscale, matched with errors.Is so callers can react to
+// a failure without parsing its message.
+var (
+	ErrInput  = errors.New("input error")  // the source can't be found, probed or decoded
+	ErrEngine = errors.New("engine error") // the upscale engine is unusable or failed
+	ErrEncode = errors.New("encode error") // encoding, audio extraction or muxing failed
+)
+
+// kindError tags an error with its failure kind, the message stays the one of err.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// withKind tags err with a failure kind, nil stays nil.
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// FailureKind returns the failure kind of err, empty when it has none.
+func FailureKind(err error) constants.JobFailure {
+	switch {
+	case errors.Is(err, ErrInput):
+		return constants.JobFailureInput
+	case errors.Is(err, ErrEngine):
+		return constants.JobFailureEngine
+	case errors.Is(err, ErrEncode):
+		return constants.JobFailureEncode
+	}
+	return constants.JobFailureOther
+}
@@ -0,0 +1,368 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+var (
+	// ErrJobNotFound is returned when a job ID is unknown to the manager.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobActive is returned when discarding a job that is queued or running.
+	ErrJobActive = errors.New("job is queued or running, cancel it first")
+)
+
+type job struct {
+	info      datatransfers.JobInfo
+	params    *datatransfers.VideoUpscalerRequest
+	resumeDir string // set when the job resumes an interrupted run
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// JobManager queues upscale jobs and runs them one at a time, each with its own
+// cancellable context so a single file can be dropped without stopping the others.
+type JobManager struct {
+	logger        *utils.CustomLogger
+	videoUpscaler VideoUpscalerUsecase
+
+	mu          sync.Mutex
+	jobs        map[string]*job
+	order       []string // job IDs in submission order
+	pending     []*job
+	wake        chan struct{}
+	subscribers map[chan datatransfers.ProgressEvent]struct{}
+}
+
+func NewJobManager(logger *utils.CustomLogger, videoUpscaler VideoUpscalerUsecase) *JobManager {
+	return &JobManager{
+		logger:        logger,
+		videoUpscaler: videoUpscaler,
+		jobs:          make(map[string]*job),
+		wake:          make(chan struct{}, 1),
+		subscribers:   make(map[chan datatransfers.ProgressEvent]struct{}),
+	}
+}
+
+// Start runs the worker and the progress dispatcher until ctx is done.
+func (m *JobManager) Start(ctx context.Context) {
+	go m.worker(ctx)
+	go m.dispatchProgress(ctx)
+}
+
+// Subscribe returns a channel receiving the progress events of every job.
+// The returned function must be called to stop receiving.
+func (m *JobManager) Subscribe() (<-chan datatransfers.ProgressEvent, func()) {
+	ch := make(chan datatransfers.ProgressEvent, 64)
+
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		delete(m.subscribers, ch)
+		m.mu.Unlock()
+	}
+}
+
+// dispatchProgress records the latest progress of each job and fans events out
+// to the subscribers. Slow subscribers miss events instead of stalling the jobs.
+func (m *JobManager) dispatchProgress(ctx context.Context) {
+	for {
+		select {
+		case event := <-m.videoUpscaler.Progress():
+			m.mu.Lock()
+			if j, ok := m.jobs[event.JobID]; ok {
+				j.info.Progress = event
+			}
+			for ch := range m.subscribers {
+				select {
+				case ch <- event:
+				default:
+				}
+			}
+			m.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Submit queues an upscale job. The job ID is the name of the job temp dir.
+func (m *JobManager) Submit(params *datatransfers.VideoUpscalerRequest) string {
+	params.JobID = filepath.Base(params.TempDir)
+	return m.enqueue(params.JobID, params.InputFullFileName, params, "")
+}
+
+// PrepareRequestFunc completes a request built by NewFileRequest, applying the
+// named preset when not empty and the configured defaults.
+type PrepareRequestFunc func(request *datatransfers.VideoUpscalerRequest, presetName string) error
+
+// NewFileRequest builds the request of a job reading inputPath in place. The job
+// data goes to a new dir under jobsFolder and the result to outputFolder.
+func NewFileRequest(inputPath, jobsFolder, outputFolder string) (*datatransfers.VideoUpscalerRequest, error) {
+	inputPath, err := filepath.Abs(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", inputPath)
+	}
+
+	tempDir := filepath.Join(jobsFolder, uuid.New().String())
+	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create job dir: %v", err)
+	}
+
+	return &datatransfers.VideoUpscalerRequest{
+		InputPlainFileName: strings.TrimSuffix(fileInfo.Name(), filepath.Ext(inputPath)),
+		InputFullFileName:  fileInfo.Name(),
+		InputFileExt:       filepath.Ext(inputPath),
+		InputFileSize:      fileInfo.Size(),
+		TempFilePath:       inputPath,
+		TempDir:            tempDir,
+		SavePath:           filepath.Join(outputFolder, fmt.Sprintf("%d_upscaled_", utils.NowUnix())+fileInfo.Name()),
+	}, nil
+}
+
+// SubmitResume queues the resume of an interrupted job found in jobDir. A job
+// already queued or running with the same ID is left alone and its ID returned.
+func (m *JobManager) SubmitResume(jobDir string) string {
+	return m.enqueue(filepath.Base(jobDir), filepath.Base(jobDir), nil, jobDir)
+}
+
+func (m *JobManager) enqueue(id, fileName string, params *datatransfers.VideoUpscalerRequest, resumeDir string) string {
+	j := &job{
+		info: datatransfers.JobInfo{
+			ID:        id,
+			FileName:  fileName,
+			State:     constants.JobStateQueued,
+			CreatedAt: time.Now(),
+		},
+		params:    params,
+		resumeDir: resumeDir,
+		done:      make(chan struct{}),
+	}
+	if params != nil {
+		j.info.Plan = params.Plan
+	}
+
+	m.mu.Lock()
+	existing, exists := m.jobs[id]
+	if exists && (existing.info.State == constants.JobStateQueued || existing.info.State == constants.JobStateRunning) {
+		// The job dir is already in use, a second worker on it would corrupt it
+		m.mu.Unlock()
+		m.logger.Warning(fmt.Sprintf("Job %s (%s) is already %s", id, fileName, existing.info.State))
+		return id
+	}
+	if !exists {
+		m.order = append(m.order, id)
+	}
+	m.jobs[id] = j
+	m.pending = append(m.pending, j)
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return id
+}
+
+// Wait blocks until the job is finished and returns its final state.
+func (m *JobManager) Wait(id string) (datatransfers.JobInfo, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return datatransfers.JobInfo{}, ErrJobNotFound
+	}
+
+	<-j.done
+	return m.Get(id)
+}
+
+// Get returns a snapshot of a job.
+func (m *JobManager) Get(id string) (datatransfers.JobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return datatransfers.JobInfo{}, ErrJobNotFound
+	}
+	return j.info, nil
+}
+
+// ListJobs returns a snapshot of every known job in submission order.
+func (m *JobManager) ListJobs() []datatransfers.JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]datatransfers.JobInfo, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, m.jobs[id].info)
+	}
+	return jobs
+}
+
+// CancelJob cancels a queued or running job, finished jobs are left untouched.
+func (m *JobManager) CancelJob(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	m.cancelLocked(j)
+	return nil
+}
+
+// CancelAll cancels every queued and running job.
+func (m *JobManager) CancelAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, j := range m.jobs {
+		m.cancelLocked(j)
+	}
+}
+
+// DiscardJob deletes the dir of a failed, cancelled or interrupted job, with its
+// copy of the input and its encoded batches. It can't be resumed afterwards.
+func (m *JobManager) DiscardJob(jobDir string) error {
+	id := filepath.Base(jobDir)
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrJobNotFound // never a parent of the job dirs
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if j, ok := m.jobs[id]; ok && (j.info.State == constants.JobStateQueued || j.info.State == constants.JobStateRunning) {
+		return ErrJobActive
+	}
+	if _, err := os.Stat(jobDir); err != nil {
+		return ErrJobNotFound
+	}
+	if err := os.RemoveAll(jobDir); err != nil {
+		return fmt.Errorf("failed to discard job %s: %v", id, err)
+	}
+	m.logger.Info(fmt.Sprintf("🗑️ Discarded job %s", id))
+	return nil
+}
+
+func (m *JobManager) cancelLocked(j *job) {
+	switch j.info.State {
+	case constants.JobStateQueued:
+		j.info.State = constants.JobStateCancelled
+		j.info.FinishedAt = time.Now()
+		close(j.done)
+	case constants.JobStateRunning:
+		j.cancel()
+	default:
+		return
+	}
+	m.logger.Warning(fmt.Sprintf("Job %s (%s) canceled by user", j.info.ID, j.info.FileName))
+}
+
+// next pops the next job still queued, nil when there is none.
+func (m *JobManager) next(ctx context.Context) *job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.pending) > 0 {
+		j := m.pending[0]
+		m.pending = m.pending[1:]
+		if j.info.State != constants.JobStateQueued {
+			continue // cancelled while waiting
+		}
+
+		j.ctx, j.cancel = context.WithCancel(ctx)
+		j.info.State = constants.JobStateRunning
+		j.info.StartedAt = time.Now()
+		return j
+	}
+	return nil
+}
+
+func (m *JobManager) worker(ctx context.Context) {
+	for {
+		j := m.next(ctx)
+		if j == nil {
+			select {
+			case <-m.wake:
+				continue
+			case <-ctx.Done():
+				m.CancelAll()
+				return
+			}
+		}
+
+		m.run(j)
+	}
+}
+
+func (m *JobManager) run(j *job) {
+	var (
+		savePath string
+		err      error
+	)
+
+	jobCtx := j.ctx
+	if j.resumeDir != "" {
+		savePath, err = m.videoUpscaler.ResumeJob(jobCtx, j.resumeDir)
+	} else {
+		err = m.videoUpscaler.UpscaleVideoWithRealESRGAN(jobCtx, j.params)
+		savePath = j.params.SavePath
+	}
+
+	// A job stopped before its manifest was written can't be resumed, its dir is only litter
+	if err != nil && j.params != nil && !hasJobManifest(j.params.TempDir) {
+		os.RemoveAll(j.params.TempDir)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Only an upscale interrupted by its context is a cancel, releasing the context comes after
+	cancelled := err != nil && jobCtx.Err() != nil
+	j.cancel()
+	j.info.FinishedAt = time.Now()
+	switch {
+	case cancelled:
+		j.info.State = constants.JobStateCancelled
+		j.info.Error = context.Canceled.Error()
+	case err != nil:
+		j.info.State = constants.JobStateFailed
+		j.info.Error = err.Error()
+		j.info.Failure = FailureKind(err)
+	default:
+		j.info.State = constants.JobStateDone
+		j.info.SavePath = savePath
+		if j.params != nil {
+			j.info.Plan = j.params.Plan
+		}
+	}
+	close(j.done)
+}
@@ -0,0 +1,277 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// stubUpscaler runs upscale instead of a real upscale, the other methods are not used by the job manager.
+type stubUpscaler struct {
+	VideoUpscalerUsecase
+	upscale  func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error
+	calls    atomic.Int32
+	progress chan datatransfers.ProgressEvent
+}
+
+func (s *stubUpscaler) UpscaleVideoWithRealESRGAN(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+	s.calls.Add(1)
+	return s.upscale(ctx, params)
+}
+
+func (s *stubUpscaler) ResumeJob(ctx context.Context, jobDir string) (string, error) {
+	s.calls.Add(1)
+	return jobDir + ".mp4", s.upscale(ctx, nil)
+}
+
+func (s *stubUpscaler) Progress() <-chan datatransfers.ProgressEvent {
+	return s.progress
+}
+
+func newTestLogger(t *testing.T) *utils.CustomLogger {
+	t.Helper()
+	logger, err := utils.NewCustomLogger(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+func startJobManager(t *testing.T, upscale func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error) (*JobManager, *stubUpscaler) {
+	t.Helper()
+	stub := &stubUpscaler{upscale: upscale, progress: make(chan datatransfers.ProgressEvent)}
+	manager := NewJobManager(newTestLogger(t), stub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	manager.Start(ctx)
+	return manager, stub
+}
+
+func TestJobManagerFinalState(t *testing.T) {
+	tests := []struct {
+		name        string
+		upscale     func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error
+		cancel      bool
+		wantState   constants.JobState
+		wantError   string
+		wantFailure constants.JobFailure
+		wantSaved   bool
+	}{
+		{
+			name:      "done",
+			upscale:   func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error { return nil },
+			wantState: constants.JobStateDone,
+			wantSaved: true,
+		},
+		{
+			name: "failed",
+			upscale: func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+				return errors.New("disk full")
+			},
+			wantState: constants.JobStateFailed,
+			wantError: "disk full",
+		},
+		{
+			name: "encode failed",
+			upscale: func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+				return withKind(ErrEncode, errors.New("encoder crashed"))
+			},
+			wantState:   constants.JobStateFailed,
+			wantError:   "encoder crashed",
+			wantFailure: constants.JobFailureEncode,
+		},
+		{
+			name: "cancelled",
+			upscale: func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			cancel:    true,
+			wantState: constants.JobStateCancelled,
+			wantError: context.Canceled.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			manager, _ := startJobManager(t, func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+				close(started)
+				return tt.upscale(ctx, params)
+			})
+
+			params := &datatransfers.VideoUpscalerRequest{TempDir: filepath.Join(t.TempDir(), "job"), SavePath: "out.mp4"}
+			id := manager.Submit(params)
+			<-started
+			if tt.cancel {
+				if err := manager.CancelJob(id); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info, err := manager.Wait(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.State != tt.wantState || info.Error != tt.wantError || info.Failure != tt.wantFailure {
+				t.Errorf("state = %s, error = %q, failure = %q, want %s, %q, %q", info.State, info.Error, info.Failure, tt.wantState, tt.wantError, tt.wantFailure)
+			}
+			if saved := info.SavePath == "out.mp4"; saved != tt.wantSaved {
+				t.Errorf("savePath = %q", info.SavePath)
+			}
+		})
+	}
+}
+
+func TestJobManagerRejectsDuplicateRunningJob(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	manager, stub := startJobManager(t, func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	jobDir := filepath.Join(t.TempDir(), "job")
+	id := manager.SubmitResume(jobDir)
+	<-started
+
+	if again := manager.SubmitResume(jobDir); again != id {
+		t.Fatalf("resubmit returned %s, want %s", again, id)
+	}
+	close(release)
+
+	info, err := manager.Wait(id)
+	if err != nil || info.State != constants.JobStateDone {
+		t.Fatalf("state = %s, err = %v", info.State, err)
+	}
+	if calls := stub.calls.Load(); calls != 1 {
+		t.Errorf("upscaler ran %d times, want 1", calls)
+	}
+	if jobs := manager.ListJobs(); len(jobs) != 1 {
+		t.Errorf("%d jobs listed, want 1", len(jobs))
+	}
+}
+
+func TestJobManagerListsPlanBeforeStart(t *testing.T) {
+	release := make(chan struct{})
+	manager, _ := startJobManager(t, func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+		<-release
+		params.Plan.OutputWidth, params.Plan.OutputHeight = 1920, 1080 // planned again at start
+		return nil
+	})
+
+	// The first job holds the worker so the second one stays queued
+	manager.Submit(&datatransfers.VideoUpscalerRequest{TempDir: filepath.Join(t.TempDir(), "first")})
+	plan := datatransfers.ResolutionPlan{SourceWidth: 640, SourceHeight: 360, ModelScale: 2, OutputWidth: 1280, OutputHeight: 720}
+	id := manager.Submit(&datatransfers.VideoUpscalerRequest{TempDir: filepath.Join(t.TempDir(), "second"), Plan: plan})
+
+	info, err := manager.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != constants.JobStateQueued || info.Plan.OutputWidth != 1280 || info.Plan.OutputHeight != 720 {
+		t.Errorf("state = %s, plan output %dx%d, want queued with 1280x720", info.State, info.Plan.OutputWidth, info.Plan.OutputHeight)
+	}
+
+	close(release)
+	if info, err = manager.Wait(id); err != nil {
+		t.Fatal(err)
+	}
+	if info.Plan.OutputWidth != 1920 || info.Plan.OutputHeight != 1080 {
+		t.Errorf("finished plan output %dx%d, want the plan run 1920x1080", info.Plan.OutputWidth, info.Plan.OutputHeight)
+	}
+}
+
+// newJobDir creates a job dir under a temp jobs folder, with a manifest when resumable.
+func newJobDir(t *testing.T, resumable bool) string {
+	t.Helper()
+	jobDir := filepath.Join(t.TempDir(), uuid.New().String())
+	if err := os.Mkdir(jobDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if resumable {
+		if err := os.WriteFile(filepath.Join(jobDir, constants.JobManifestFileName), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return jobDir
+}
+
+func TestJobManagerKeepsOnlyResumableDirs(t *testing.T) {
+	tests := []struct {
+		name      string
+		resumable bool
+		fail      bool
+		wantDir   bool
+	}{
+		{name: "failed with a manifest", resumable: true, fail: true, wantDir: true},
+		{name: "failed before the manifest", fail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, _ := startJobManager(t, func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+				if tt.fail {
+					return errors.New("disk full")
+				}
+				return nil
+			})
+
+			jobDir := newJobDir(t, tt.resumable)
+			if _, err := manager.Wait(manager.Submit(&datatransfers.VideoUpscalerRequest{TempDir: jobDir})); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(jobDir); (err == nil) != tt.wantDir {
+				t.Errorf("job dir exists = %v, want %v", err == nil, tt.wantDir)
+			}
+		})
+	}
+}
+
+func TestJobManagerDiscardJob(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	manager, _ := startJobManager(t, func(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+		close(started)
+		<-release
+		return errors.New("disk full")
+	})
+
+	jobDir := newJobDir(t, true)
+	id := manager.Submit(&datatransfers.VideoUpscalerRequest{TempDir: jobDir})
+	<-started
+	if err := manager.DiscardJob(jobDir); !errors.Is(err, ErrJobActive) {
+		t.Fatalf("discarding a running job: err = %v, want %v", err, ErrJobActive)
+	}
+
+	close(release)
+	if _, err := manager.Wait(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.DiscardJob(jobDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(jobDir); !os.IsNotExist(err) {
+		t.Errorf("job dir still exists: %v", err)
+	}
+
+	if err := manager.DiscardJob(jobDir); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("discarding twice: err = %v, want %v", err, ErrJobNotFound)
+	}
+	jobsFolder := filepath.Dir(jobDir)
+	if err := manager.DiscardJob(filepath.Join(jobsFolder, ".")); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("discarding the jobs folder: err = %v, want %v", err, ErrJobNotFound)
+	}
+	if _, err := os.Stat(jobsFolder); err != nil {
+		t.Errorf("jobs folder is gone: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+// hashFile returns the hex encoded sha256 of a file.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadJobManifest reads the manifest of a job directory, it returns nil when the job has none yet.
+func loadJobManifest(jobDir string) (*datatransfers.JobManifest, error) {
+	data, err := os.ReadFile(filepath.Join(jobDir, constants.JobManifestFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest datatransfers.JobManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid job manifest in %s: %v", jobDir, err)
+	}
+	return &manifest, nil
+}
+
+// saveJobManifest writes the manifest atomically so a crash never leaves a truncated file.
+func saveJobManifest(manifest *datatransfers.JobManifest) error {
+	manifest.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	manifestPath := filepath.Join(manifest.Request.TempDir, constants.JobManifestFileName)
+	tmpPath := manifestPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, manifestPath)
+}
+
+// hasJobManifest reports whether the job of jobDir got far enough to be resumed.
+func hasJobManifest(jobDir string) bool {
+	_, err := os.Stat(filepath.Join(jobDir, constants.JobManifestFileName))
+	return err == nil
+}
+
+// isBatchCompleted reports whether the batch is recorded in the manifest and its video still exists.
+func isBatchCompleted(manifest *datatransfers.JobManifest, index int) (string, bool) {
+	for _, batch := range manifest.CompletedBatches {
+		if batch.Index != index {
+			continue
+		}
+		if _, err := os.Stat(batch.VideoPath); err != nil {
+			return "", false
+		}
+		return batch.VideoPath, true
+	}
+	return "", false
+}
+
+// openJobManifest returns the manifest of params.TempDir, creating it when the job is new.
+// An existing manifest is only reused when the input and the settings are unchanged,
+// its batches are kept so scene detection is not run again. The input is hashed
+// unless inputHash already holds its hash.
+func (u *videoUpscalerUsecase) openJobManifest(ctx context.Context, params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse, inputHash string) (*datatransfers.JobManifest, error) {
+	totalFrames := params.EndFrame - params.StartFrame
+
+	if inputHash == "" {
+		var err error
+		if inputHash, err = hashFile(params.TempFilePath); err != nil {
+			return nil, fmt.Errorf("failed to hash input: %v", err)
+		}
+	}
+
+	manifest, err := loadJobManifest(params.TempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest != nil {
+		if manifestMatches(manifest, params, inputHash, totalFrames) {
+			u.logger.Info(fmt.Sprintf("♻️ Resuming job %s, %d/%d batches already done", manifest.JobID, len(manifest.CompletedBatches), manifest.TotalBatches))
+			return manifest, nil
+		}
+		u.logger.Warning(fmt.Sprintf("⚠️ Job manifest in %s does not match the input or settings, starting over", params.TempDir))
+	}
+
+	sceneCuts, batches, err := u.planBatches(ctx, params, videoMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest = &datatransfers.JobManifest{
+		JobID:        filepath.Base(params.TempDir),
+		InputHash:    inputHash,
+		Request:      *params,
+		BatchSize:    constants.MaxBatchFrames,
+		TotalFrames:  totalFrames,
+		TotalBatches: len(batches),
+		SceneCuts:    sceneCuts,
+		Batches:      batches,
+		CreatedAt:    time.Now(),
+	}
+	return manifest, saveJobManifest(manifest)
+}
+
+// manifestMatches tells whether the batches of manifest can be reused for params,
+// the input and every setting ending up in the encoded batches must be unchanged.
+func manifestMatches(manifest *datatransfers.JobManifest, params *datatransfers.VideoUpscalerRequest, inputHash string, totalFrames int) bool {
+	return manifest.InputHash == inputHash && manifest.BatchSize == constants.MaxBatchFrames && manifest.TotalFrames == totalFrames &&
+		manifest.Request.Model == params.Model && reflect.DeepEqual(manifest.Request.Plan, params.Plan) &&
+		manifest.Request.FrameRate == params.FrameRate && manifest.Request.FrameRateMode == params.FrameRateMode &&
+		manifest.Request.StartFrame == params.StartFrame && manifest.Request.EndFrame == params.EndFrame &&
+		manifest.Request.SceneThreshold == params.SceneThreshold && manifest.Request.FixedBatches == params.FixedBatches &&
+		reflect.DeepEqual(manifest.Request.Encoder, params.Encoder) && manifest.Request.Container == params.Container &&
+		manifest.Request.Audio == params.Audio &&
+		len(manifest.Batches) > 0
+}
+
+// ResumeJob restarts an interrupted job from its directory, skipping finished
+// batches. It returns the path of the final video.
+func (u *videoUpscalerUsecase) ResumeJob(ctx context.Context, jobDir string) (string, error) {
+	manifest, err := loadJobManifest(jobDir)
+	if err != nil {
+		return "", err
+	}
+	if manifest == nil {
+		return "", fmt.Errorf("no job manifest found in %s", jobDir)
+	}
+
+	inputHash, err := hashFile(manifest.Request.TempFilePath)
+	if err != nil {
+		return "", fmt.Errorf("input of job %s is gone: %v", manifest.JobID, err)
+	}
+	if inputHash != manifest.InputHash {
+		return "", fmt.Errorf("input of job %s changed since it was started", manifest.JobID)
+	}
+
+	// The hash is passed down, hashing a large input again would double the startup
+	params := manifest.Request
+	if err := u.upscaleVideo(ctx, &params, inputHash); err != nil {
+		return "", err
+	}
+	return params.SavePath, nil
+}
+
+// ListResumableJobs returns the manifests of every unfinished job under jobsDir, oldest first.
+func (u *videoUpscalerUsecase) ListResumableJobs(jobsDir string) ([]*datatransfers.JobManifest, error) {
+	entries, err := os.ReadDir(jobsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []*datatransfers.JobManifest{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := loadJobManifest(filepath.Join(jobsDir, entry.Name()))
+		if err != nil {
+			u.logger.Warning(err.Error())
+			continue
+		}
+		if manifest != nil {
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.Before(manifests[j].CreatedAt)
+	})
+	return manifests, nil
+}
+
+// PruneJobDirs removes the dirs under jobsDir left by jobs that crashed or were
+// killed before their manifest was written, nothing can resume them. Only dirs
+// untouched for constants.StaleJobDirAge are removed.
+func (u *videoUpscalerUsecase) PruneJobDirs(jobsDir string) error {
+	entries, err := os.ReadDir(jobsDir)
+	if err != nil {
+		return err
+	}
+
+	pruned := 0
+	for _, entry := range entries {
+		jobDir := filepath.Join(jobsDir, entry.Name())
+		if !entry.IsDir() || hasJobManifest(jobDir) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < constants.StaleJobDirAge {
+			continue
+		}
+		if err := os.RemoveAll(jobDir); err != nil {
+			u.logger.Warning(fmt.Sprintf("⚠️ Failed to remove abandoned job dir %s: %v", jobDir, err))
+			continue
+		}
+		pruned++
+	}
+
+	if pruned > 0 {
+		u.logger.Info(fmt.Sprintf("🧹 Removed %d abandoned job dirs from %s", pruned, jobsDir))
+	}
+	return nil
+}
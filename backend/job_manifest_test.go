@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+func TestManifestMatches(t *testing.T) {
+	request := datatransfers.VideoUpscalerRequest{
+		Model:     "realesr-animevideov3-x2",
+		EndFrame:  100,
+		FrameRate: datatransfers.Rational{Num: 24000, Den: 1001},
+		Encoder:   datatransfers.EncoderOptions{Codec: "libx264", CRF: intPtr(18), Preset: "slow"},
+		Audio:     datatransfers.AudioOptions{Codec: "aac", Bitrate: "192k"},
+		Container: "mp4",
+	}
+
+	tests := []struct {
+		name   string
+		change func(params *datatransfers.VideoUpscalerRequest)
+		want   bool
+	}{
+		{name: "unchanged", change: func(params *datatransfers.VideoUpscalerRequest) {}, want: true},
+		{name: "model", change: func(params *datatransfers.VideoUpscalerRequest) { params.Model = "realesrgan-x4plus" }},
+		{name: "frame rate", change: func(params *datatransfers.VideoUpscalerRequest) {
+			params.FrameRate = datatransfers.Rational{Num: 24, Den: 1}
+		}},
+		{name: "trim", change: func(params *datatransfers.VideoUpscalerRequest) { params.StartFrame = 10 }},
+		{name: "encoder codec", change: func(params *datatransfers.VideoUpscalerRequest) { params.Encoder.Codec = "libx265" }},
+		{name: "encoder crf", change: func(params *datatransfers.VideoUpscalerRequest) { params.Encoder.CRF = intPtr(23) }},
+		{name: "encoder preset", change: func(params *datatransfers.VideoUpscalerRequest) { params.Encoder.Preset = "fast" }},
+		{name: "container", change: func(params *datatransfers.VideoUpscalerRequest) { params.Container = "mkv" }},
+		{name: "audio codec", change: func(params *datatransfers.VideoUpscalerRequest) { params.Audio.Codec = "libopus" }},
+		{name: "audio bitrate", change: func(params *datatransfers.VideoUpscalerRequest) { params.Audio.Bitrate = "320k" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &datatransfers.JobManifest{
+				InputHash:   "abc",
+				Request:     request,
+				BatchSize:   constants.MaxBatchFrames,
+				TotalFrames: 100,
+				Batches:     []datatransfers.FrameRange{{StartFrame: 0, EndFrame: 99}},
+			}
+			params := request
+			tt.change(&params)
+
+			if got := manifestMatches(manifest, &params, "abc", 100); got != tt.want {
+				t.Errorf("manifestMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneJobDirs(t *testing.T) {
+	jobsDir := t.TempDir()
+	stale := time.Now().Add(-constants.StaleJobDirAge - time.Hour)
+
+	newDir := func(name string, resumable bool, modTime time.Time) string {
+		jobDir := filepath.Join(jobsDir, name)
+		if err := os.Mkdir(jobDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if resumable {
+			if err := os.WriteFile(filepath.Join(jobDir, constants.JobManifestFileName), []byte("{}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Chtimes(jobDir, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return jobDir
+	}
+	abandoned := newDir("abandoned", false, stale)
+	resumable := newDir("resumable", true, stale)
+	preparing := newDir("preparing", false, time.Now())
+
+	u := NewVideoUpscaler(newTestLogger(t), &sync.Map{})
+	if err := u.PruneJobDirs(jobsDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for jobDir, want := range map[string]bool{abandoned: false, resumable: true, preparing: true} {
+		if _, err := os.Stat(jobDir); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(jobDir), err == nil, want)
+		}
+	}
+}
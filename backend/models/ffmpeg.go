@@ -2,9 +2,28 @@ package models
 
 type FFProbeOutput struct {
 	Streams []struct {
-		NbFrames   string `json:"nb_frames"`
-		RFrameRate string `json:"r_frame_rate"`
-		Width      int    `json:"width"`
-		Height     int    `json:"height"`
+		NbFrames     string `json:"nb_frames"`
+		RFrameRate   string `json:"r_frame_rate"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		Duration     string `json:"duration"`
+		StartTime    string `json:"start_time"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+	} `json:"streams"`
+	Format struct {
+		Duration  string `json:"duration"`
+		StartTime string `json:"start_time"`
+	} `json:"format"`
+}
+
+// FFProbeStreamListOutput lists every stream of a file, `-show_entries stream=index,codec_type,codec_name:stream_tags=language`.
+type FFProbeStreamListOutput struct {
+	Streams []struct {
+		Index     int    `json:"index"`
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Tags      struct {
+			Language string `json:"language"`
+		} `json:"tags"`
 	} `json:"streams"`
 }
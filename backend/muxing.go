@@ -0,0 +1,305 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/models"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+const (
+	streamTypeAudio      = "audio"
+	streamTypeSubtitle   = "subtitle"
+	streamTypeAttachment = "attachment"
+)
+
+// containerSpec lists what a container can hold and how ffmpeg should mux it.
+type containerSpec struct {
+	videoCodecs       []string // encoders whose output the container accepts
+	audioCopyCodecs   []string // source audio codecs kept as they are, nil : any
+	audioCodec        string   // default encoder of the tracks that can't be copied
+	subtitleCodec     string   // subtitles are converted to this codec, copy keeps them as they are
+	textSubtitlesOnly bool     // bitmap subtitles (PGS, VobSub) can't be converted and are dropped
+	attachments       bool     // fonts and other attachments are kept
+	args              []string // extra muxer options
+}
+
+var containerListOrder = []string{"mp4", "mkv", "mov", "webm"}
+
+// audioEncoders maps the supported fallback audio encoders to the codec they produce.
+var audioEncoders = map[string]string{
+	"aac":        "aac",
+	"libopus":    "opus",
+	"flac":       "flac",
+	"alac":       "alac",
+	"ac3":        "ac3",
+	"libmp3lame": "mp3",
+	"libvorbis":  "vorbis",
+}
+
+// audioFileExtensions is the raw format a single extracted audio track is stored
+// in, other codecs and multiple tracks go to Matroska audio.
+var audioFileExtensions = map[string]string{
+	"aac":       ".aac",
+	"ac3":       ".ac3",
+	"eac3":      ".eac3",
+	"flac":      ".flac",
+	"mp3":       ".mp3",
+	"opus":      ".opus",
+	"vorbis":    ".ogg",
+	"alac":      ".m4a",
+	"pcm_s16le": ".wav",
+	"pcm_s24le": ".wav",
+	"pcm_f32le": ".wav",
+}
+
+// textSubtitleCodecs can be converted between each other, the others are bitmaps.
+var textSubtitleCodecs = map[string]bool{
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"mov_text": true,
+	"text":     true,
+}
+
+// sourceStream is a stream of the input as listed by ffprobe.
+type sourceStream struct {
+	index     int
+	codecType string
+	codecName string
+	language  string
+}
+
+var containerSpecs = map[string]containerSpec{
+	"mp4": {
+		videoCodecs:       []string{"libx264", "libx265", "libsvtav1", "libaom-av1", "libvpx-vp9"},
+		audioCopyCodecs:   []string{"aac", "mp3", "ac3", "eac3", "alac", "flac", "opus"},
+		audioCodec:        "aac",
+		subtitleCodec:     "mov_text",
+		textSubtitlesOnly: true,
+		args:              []string{"-movflags", "+faststart"}, // index first so playback starts before the download ends
+	},
+	"mkv": {
+		videoCodecs:   encoderListOrder,
+		audioCodec:    "aac",
+		subtitleCodec: "copy",
+		attachments:   true,
+	},
+	"mov": {
+		videoCodecs:       []string{"libx264", "libx265", "ffv1"},
+		audioCopyCodecs:   []string{"aac", "mp3", "ac3", "eac3", "alac", "pcm_s16le", "pcm_s24le", "pcm_s16be", "pcm_s24be", "pcm_f32le"},
+		audioCodec:        "aac",
+		subtitleCodec:     "mov_text",
+		textSubtitlesOnly: true,
+		args:              []string{"-movflags", "+faststart"},
+	},
+	"webm": {
+		videoCodecs:       []string{"libvpx-vp9", "libsvtav1", "libaom-av1"},
+		audioCopyCodecs:   []string{"opus", "vorbis"},
+		audioCodec:        "libopus",
+		subtitleCodec:     "webvtt",
+		textSubtitlesOnly: true,
+	},
+}
+
+// ResolveContainer defaults the output container, rejects an encoder the
+// container can't hold and gives SavePath the container extension. It is safe
+// to call more than once.
+func ResolveContainer(params *datatransfers.VideoUpscalerRequest) error {
+	if params.Container == "" {
+		params.Container = constants.DefaultContainer
+	}
+	params.Container = strings.ToLower(strings.TrimPrefix(params.Container, "."))
+
+	spec, ok := containerSpecs[params.Container]
+	if !ok {
+		return fmt.Errorf("unsupported container %s, expected one of %s", params.Container, strings.Join(containerListOrder, ", "))
+	}
+
+	codec := params.Encoder.Codec
+	if codec == "" {
+		codec = constants.DefaultVideoEncoder
+	}
+	if !slices.Contains(spec.videoCodecs, codec) {
+		return fmt.Errorf("%s can't be stored in %s, use one of %s or pick another container", codec, params.Container, strings.Join(spec.videoCodecs, ", "))
+	}
+
+	if params.Audio.Codec != "" {
+		audioCodec, ok := audioEncoders[params.Audio.Codec]
+		if !ok {
+			return fmt.Errorf("unsupported audio encoder %s", params.Audio.Codec)
+		}
+		if !spec.canCopyAudio(audioCodec) {
+			return fmt.Errorf("%s audio can't be stored in %s", audioCodec, params.Container)
+		}
+	}
+	if params.Audio.Bitrate != "" && !bitratePattern.MatchString(params.Audio.Bitrate) {
+		return fmt.Errorf("invalid audio bitrate %q, expected e.g. 192k", params.Audio.Bitrate)
+	}
+
+	if params.SavePath != "" {
+		params.SavePath = strings.TrimSuffix(params.SavePath, filepath.Ext(params.SavePath)) + "." + params.Container
+	}
+	return nil
+}
+
+// canCopyAudio reports whether audio of the given codec can be muxed as it is.
+func (spec containerSpec) canCopyAudio(codec string) bool {
+	return spec.audioCopyCodecs == nil || slices.Contains(spec.audioCopyCodecs, codec)
+}
+
+// audioFileName returns the name of the intermediate audio file, its extension
+// follows the codec of the audio tracks.
+func audioFileName(plainFileName string, streams []sourceStream) string {
+	ext := ".mka"
+	if countStreams(streams, streamTypeAudio) == 1 {
+		for _, stream := range streams {
+			if stream.codecType == streamTypeAudio && audioFileExtensions[stream.codecName] != "" {
+				ext = audioFileExtensions[stream.codecName]
+			}
+		}
+	}
+	return plainFileName + ext
+}
+
+// muxArgs returns the output options of the final mux for the resolved container.
+// The video and audio are mapped by the caller, the subtitles, attachments,
+// chapters and metadata are taken from streams of the source at input sourceInput.
+func (u *videoUpscalerUsecase) muxArgs(params *datatransfers.VideoUpscalerRequest, streams []sourceStream, sourceInput int) []string {
+	spec := containerSpecs[params.Container]
+
+	// Global and video tags (title, language...) and chapters come from the source
+	args := []string{
+		"-map_metadata", fmt.Sprint(sourceInput),
+		"-map_metadata:s:v:0", fmt.Sprintf("%d:s:v:0", sourceInput),
+		"-map_chapters", fmt.Sprint(sourceInput),
+	}
+
+	audioTracks, subtitles, attachments := 0, 0, 0
+	for _, stream := range streams {
+		switch stream.codecType {
+		case streamTypeAudio:
+			if !params.IsHaveAudio {
+				continue
+			}
+			// Tracks are copied when the container allows it, raw audio files lose the tags so they come from the source
+			args = append(args, fmt.Sprintf("-map_metadata:s:a:%d", audioTracks), fmt.Sprintf("%d:s:a:%d", sourceInput, audioTracks))
+			args = append(args, u.audioTrackArgs(params, spec, stream, audioTracks)...)
+			audioTracks++
+		case streamTypeSubtitle:
+			if spec.textSubtitlesOnly && !textSubtitleCodecs[stream.codecName] {
+				u.logger.Warning(fmt.Sprintf("⚠️ Dropping %s subtitle #%d (%s), %s only holds text subtitles", stream.codecName, stream.index, stream.language, params.Container))
+				continue
+			}
+			codec := spec.subtitleCodec
+			if codec == "copy" && stream.codecName == "mov_text" {
+				codec = "srt" // mov_text only exists in mp4/mov
+			}
+			args = append(args, "-map", fmt.Sprintf("%d:%d", sourceInput, stream.index), fmt.Sprintf("-c:s:%d", subtitles), codec)
+			subtitles++
+		case streamTypeAttachment:
+			if !spec.attachments {
+				u.logger.Warning(fmt.Sprintf("⚠️ Dropping attachment #%d, %s can't hold attachments", stream.index, params.Container))
+				continue
+			}
+			args = append(args, "-map", fmt.Sprintf("%d:%d", sourceInput, stream.index))
+			attachments++
+		}
+	}
+	if attachments > 0 {
+		args = append(args, "-c:t", "copy")
+	}
+
+	// Apple players only recognise HEVC in mp4/mov with the hvc1 tag
+	if params.Encoder.Codec == "libx265" && (params.Container == "mp4" || params.Container == "mov") {
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return append(args, spec.args...)
+}
+
+// audioTrackArgs returns the codec options of the output audio track n.
+func (u *videoUpscalerUsecase) audioTrackArgs(params *datatransfers.VideoUpscalerRequest, spec containerSpec, stream sourceStream, n int) []string {
+	if spec.canCopyAudio(stream.codecName) {
+		return []string{fmt.Sprintf("-c:a:%d", n), "copy"}
+	}
+
+	encoder := params.Audio.Codec
+	if encoder == "" {
+		encoder = spec.audioCodec
+	}
+	bitrate := params.Audio.Bitrate
+	if bitrate == "" {
+		bitrate = constants.DefaultAudioBitrate
+	}
+	u.logger.Info(fmt.Sprintf("🔊 %s can't hold %s audio, encoding track %d to %s %s", params.Container, stream.codecName, n+1, encoder, bitrate))
+
+	args := []string{fmt.Sprintf("-c:a:%d", n), encoder}
+	if encoder != "flac" && encoder != "alac" {
+		args = append(args, fmt.Sprintf("-b:a:%d", n), bitrate)
+	}
+	return args
+}
+
+// probeStreams lists every stream of a file.
+func (u *videoUpscalerUsecase) probeStreams(ctx context.Context, path string) ([]sourceStream, error) {
+	cmd := exec.CommandContext(ctx, config.Paths.FFprobePath, "-v", "error",
+		"-show_entries", "stream=index,codec_type,codec_name:stream_tags=language", "-of", "json", path)
+	utils.HideWindowsCMD(cmd)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var probe models.FFProbeStreamListOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, err
+	}
+
+	streams := make([]sourceStream, 0, len(probe.Streams))
+	for _, stream := range probe.Streams {
+		streams = append(streams, sourceStream{
+			index:     stream.Index,
+			codecType: stream.CodecType,
+			codecName: stream.CodecName,
+			language:  stream.Tags.Language,
+		})
+	}
+	return streams, nil
+}
+
+func countStreams(streams []sourceStream, codecType string) int {
+	count := 0
+	for _, stream := range streams {
+		if stream.codecType == codecType {
+			count++
+		}
+	}
+	return count
+}
+
+// ListContainers returns the output containers with the encoders they accept.
+func ListContainers() []datatransfers.ContainerInfo {
+	containers := make([]datatransfers.ContainerInfo, 0, len(containerListOrder))
+	for _, name := range containerListOrder {
+		spec := containerSpecs[name]
+		containers = append(containers, datatransfers.ContainerInfo{
+			Name:        name,
+			VideoCodecs: spec.videoCodecs,
+			AudioCodec:  spec.audioCodec,
+			AudioCopy:   spec.audioCopyCodecs,
+		})
+	}
+	return containers
+}
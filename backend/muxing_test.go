@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+func TestResolveContainer(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        datatransfers.VideoUpscalerRequest
+		wantContainer string
+		wantSavePath  string
+		wantErr       bool
+	}{
+		{name: "default", params: datatransfers.VideoUpscalerRequest{SavePath: "out/video.mkv"}, wantContainer: "mp4", wantSavePath: "out/video.mp4"},
+		{name: "extension spelling", params: datatransfers.VideoUpscalerRequest{Container: ".MKV", SavePath: "video.mp4"}, wantContainer: "mkv", wantSavePath: "video.mkv"},
+		{name: "lossless in mkv", params: datatransfers.VideoUpscalerRequest{Container: "mkv", Encoder: datatransfers.EncoderOptions{Codec: "ffv1"}}, wantContainer: "mkv"},
+		{name: "opus in mp4", params: datatransfers.VideoUpscalerRequest{Audio: datatransfers.AudioOptions{Codec: "libopus", Bitrate: "128k"}}, wantContainer: "mp4"},
+		{name: "unsupported container", params: datatransfers.VideoUpscalerRequest{Container: "avi"}, wantErr: true},
+		{name: "encoder the container can't hold", params: datatransfers.VideoUpscalerRequest{Encoder: datatransfers.EncoderOptions{Codec: "ffv1"}}, wantErr: true},
+		{name: "webm takes no h264", params: datatransfers.VideoUpscalerRequest{Container: "webm"}, wantErr: true},
+		{name: "audio the container can't hold", params: datatransfers.VideoUpscalerRequest{Audio: datatransfers.AudioOptions{Codec: "libvorbis"}}, wantErr: true},
+		{name: "unknown audio encoder", params: datatransfers.VideoUpscalerRequest{Audio: datatransfers.AudioOptions{Codec: "pcm"}}, wantErr: true},
+		{name: "invalid audio bitrate", params: datatransfers.VideoUpscalerRequest{Audio: datatransfers.AudioOptions{Bitrate: "high"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			err := ResolveContainer(&params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveContainer() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if params.Container != tt.wantContainer || params.SavePath != tt.wantSavePath {
+				t.Errorf("container = %s, savePath = %q, want %s, %q", params.Container, params.SavePath, tt.wantContainer, tt.wantSavePath)
+			}
+
+			// Resolving again changes nothing
+			if err := ResolveContainer(&params); err != nil || params.Container != tt.wantContainer || params.SavePath != tt.wantSavePath {
+				t.Errorf("second ResolveContainer() = %v, container = %s, savePath = %q", err, params.Container, params.SavePath)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package backend
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+// Percent ranges of the job stages, upscaling takes the bulk of the bar.
+const (
+	progressUpscaleStart = 15.0
+	progressUpscaleEnd   = 85.0
+)
+
+// progressTracker computes the progress of one job. Every method is safe to
+// call from concurrent upscale goroutines.
+type progressTracker struct {
+	mu  sync.Mutex
+	out chan<- datatransfers.ProgressEvent
+
+	event       datatransfers.ProgressEvent
+	startTime   time.Time // start of the upscaling stage
+	startFrames int       // frames already done when upscaling started (resumed jobs)
+	lastEmit    time.Time
+}
+
+// jobID returns the ID used in progress events, the job temp dir name unless set explicitly.
+func jobID(params *datatransfers.VideoUpscalerRequest) string {
+	if params.JobID != "" {
+		return params.JobID
+	}
+	return filepath.Base(params.TempDir)
+}
+
+// startProgress registers a tracker for the job, stopProgress must be called when it ends.
+func (u *videoUpscalerUsecase) startProgress(params *datatransfers.VideoUpscalerRequest) *progressTracker {
+	t := &progressTracker{
+		out: u.progress,
+		event: datatransfers.ProgressEvent{
+			JobID:    jobID(params),
+			FileName: params.InputFullFileName,
+			Stage:    constants.ProgressStageSetup,
+		},
+	}
+	u.trackers.Store(t.event.JobID, t)
+	return t
+}
+
+func (u *videoUpscalerUsecase) stopProgress(params *datatransfers.VideoUpscalerRequest) {
+	u.trackers.Delete(jobID(params))
+}
+
+// progressFor returns the tracker of a running job, nil when none is registered.
+func (u *videoUpscalerUsecase) progressFor(params *datatransfers.VideoUpscalerRequest) *progressTracker {
+	if t, ok := u.trackers.Load(jobID(params)); ok {
+		return t.(*progressTracker)
+	}
+	return nil
+}
+
+// Progress returns the channel receiving the progress events of every job.
+func (u *videoUpscalerUsecase) Progress() <-chan datatransfers.ProgressEvent {
+	return u.progress
+}
+
+// SetStage moves the job to a new stage at the given percent.
+func (t *progressTracker) SetStage(stage constants.ProgressStage, percent float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.event.Stage = stage
+	t.event.Percent = max(t.event.Percent, percent)
+	if stage == constants.ProgressStageDone {
+		t.event.ETASeconds = 0
+	}
+	t.emitLocked(true)
+}
+
+// StartUpscaling enters the upscaling stage. framesDone is non-zero for resumed jobs.
+func (t *progressTracker) StartUpscaling(totalFrames, totalBatches, framesDone int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.event.Stage = constants.ProgressStageUpscaling
+	t.event.TotalFrames = totalFrames
+	t.event.TotalBatches = totalBatches
+	t.event.FramesDone = framesDone
+	t.startFrames = framesDone
+	t.startTime = time.Now()
+	t.updateLocked()
+	t.emitLocked(true)
+}
+
+// StartBatch records the batch (1-based) currently processed.
+func (t *progressTracker) StartBatch(batch int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.event.Batch = batch
+	t.emitLocked(true)
+}
+
+// AddFrames records n more upscaled frames.
+func (t *progressTracker) AddFrames(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.event.FramesDone += n
+	t.updateLocked()
+	t.emitLocked(t.event.FramesDone == t.event.TotalFrames)
+}
+
+// updateLocked recomputes percent, throughput and ETA from the frame counters.
+func (t *progressTracker) updateLocked() {
+	if t.event.TotalFrames <= 0 {
+		return
+	}
+
+	done := min(t.event.FramesDone, t.event.TotalFrames)
+	t.event.Percent = progressUpscaleStart + (progressUpscaleEnd-progressUpscaleStart)*float64(done)/float64(t.event.TotalFrames)
+
+	elapsed := time.Since(t.startTime).Seconds()
+	framesThisRun := done - t.startFrames
+	if elapsed > 0 && framesThisRun > 0 {
+		t.event.FPS = float64(framesThisRun) / elapsed
+		t.event.ETASeconds = float64(t.event.TotalFrames-done) / t.event.FPS
+	}
+}
+
+// emitLocked sends the current event, frame updates are throttled unless forced.
+// Events are dropped rather than blocking the pipeline when nobody reads them.
+func (t *progressTracker) emitLocked(force bool) {
+	if !force && time.Since(t.lastEmit) < 250*time.Millisecond {
+		return
+	}
+	t.lastEmit = time.Now()
+
+	select {
+	case t.out <- t.event:
+	default:
+	}
+}
@@ -0,0 +1,320 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"slices"
+	"strings"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/engine"
+	"github.com/riskibarqy/RevivePixels/backend/models"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+// ValidateTarget checks the target resolution of a request before it is queued.
+func ValidateTarget(target datatransfers.TargetResolution) error {
+	switch target.Mode {
+	case constants.TargetModeNone:
+		return nil
+	case constants.TargetModeHeight:
+		if target.Height <= 0 {
+			return fmt.Errorf("target height is required")
+		}
+	case constants.TargetModeFit, constants.TargetModeExact:
+		if target.Width <= 0 || target.Height <= 0 {
+			return fmt.Errorf("target width and height are required")
+		}
+	default:
+		return fmt.Errorf("unsupported target mode %s, expected fit, height or exact", target.Mode)
+	}
+
+	if target.Fill != "" && target.Fill != constants.TargetFillPad && target.Fill != constants.TargetFillCrop {
+		return fmt.Errorf("unsupported fill %s, expected pad or crop", target.Fill)
+	}
+	return nil
+}
+
+// ValidatePreScale checks the pre-scale options of a request before it is queued.
+func ValidatePreScale(options datatransfers.PreScaleOptions) error {
+	switch options.Policy {
+	case "", constants.PreScaleAuto, constants.PreScaleNone:
+	case constants.PreScaleFactor:
+		if options.Factor <= 0 || options.Factor > 1 {
+			return fmt.Errorf("pre-scale factor must be between 0 and 1, e.g. 0.5")
+		}
+	case constants.PreScaleMaxDimension:
+		if options.MaxDimension < 2 {
+			return fmt.Errorf("pre-scale max dimension is required")
+		}
+	default:
+		return fmt.Errorf("unsupported pre-scale policy %s, expected auto, none, factor or max_dimension", options.Policy)
+	}
+	return nil
+}
+
+// ValidatePasses checks the chained passes of a request before it is queued,
+// ValidateScales checks their scales against the models.
+func ValidatePasses(passes []datatransfers.UpscalePass) error {
+	for i, pass := range passes {
+		if pass.Model == "" {
+			return fmt.Errorf("pass %d: model is required", i+1)
+		}
+		if pass.Scale < 0 {
+			return fmt.Errorf("pass %d: scale can't be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// requestPasses returns the passes a request asks for, a single-model request is one pass.
+func requestPasses(params *datatransfers.VideoUpscalerRequest) []datatransfers.UpscalePass {
+	if len(params.Passes) > 0 {
+		return params.Passes
+	}
+	return []datatransfers.UpscalePass{{Model: params.Model, Scale: params.ScaleMultiplier}}
+}
+
+// describePasses formats passes for the logs, e.g. "realesr-animevideov3-x2 2x → realesrgan-x4plus 4x".
+func describePasses(passes []datatransfers.UpscalePass) string {
+	descriptions := make([]string, 0, len(passes))
+	for _, pass := range passes {
+		descriptions = append(descriptions, fmt.Sprintf("%s %dx", pass.Model, pass.Scale))
+	}
+	return strings.Join(descriptions, " → ")
+}
+
+// autoPreScale returns the pre-scale of the auto policy for a model. The anime
+// models are capped at 720p, the others keep every source pixel.
+func autoPreScale(model string) datatransfers.PreScaleOptions {
+	if strings.Contains(strings.ToLower(model), "anime") {
+		return datatransfers.PreScaleOptions{Policy: constants.PreScaleMaxDimension, MaxDimension: constants.AnimePreScaleMaxDimension}
+	}
+	return datatransfers.PreScaleOptions{Policy: constants.PreScaleNone}
+}
+
+// even rounds a dimension to the nearest even size, yuv420p needs both to be even.
+func even(size float64) int {
+	return max(2, int(math.Round(size/2))*2)
+}
+
+// fitAspect returns the largest even size inside width x height keeping the
+// aspect ratio of the source, the size ffmpeg's scale filter gives with
+// force_original_aspect_ratio=decrease and force_divisible_by=2.
+func fitAspect(sourceWidth, sourceHeight, width, height int) (int, int) {
+	for {
+		// av_rescale rounds to nearest
+		fitWidth := min(width, (height*sourceWidth+sourceHeight/2)/sourceHeight) &^ 1
+		fitHeight := min(height, (width*sourceHeight+sourceWidth/2)/sourceWidth) &^ 1
+		if fitWidth == width && fitHeight == height {
+			return width, height
+		}
+		width, height = fitWidth, fitHeight
+	}
+}
+
+// applyPreScale fills the frame size handed to the upscale engine, the auto
+// policy is resolved for the model first. Sources are never enlarged.
+func applyPreScale(plan *datatransfers.ResolutionPlan, options datatransfers.PreScaleOptions, model string) {
+	if options.Policy == "" || options.Policy == constants.PreScaleAuto {
+		options = autoPreScale(model)
+	}
+	plan.PreScalePolicy = options.Policy
+
+	factor := 1.0
+	switch options.Policy {
+	case constants.PreScaleFactor:
+		factor = options.Factor
+	case constants.PreScaleMaxDimension:
+		factor = math.Min(1, float64(options.MaxDimension)/float64(max(plan.SourceWidth, plan.SourceHeight)))
+	}
+
+	plan.PreScaleFactor = factor
+	plan.InputWidth, plan.InputHeight = plan.SourceWidth, plan.SourceHeight
+	if factor < 1 {
+		plan.InputWidth, plan.InputHeight = fitAspect(plan.SourceWidth, plan.SourceHeight,
+			even(float64(plan.SourceWidth)*factor), even(float64(plan.SourceHeight)*factor))
+	}
+}
+
+// preScaleFilter returns the scale filter applied before upscaling, empty when
+// none is needed. The plan size already keeps the aspect ratio, so ffmpeg keeps
+// it exactly and the frames are the planned size.
+func preScaleFilter(plan *datatransfers.ResolutionPlan) string {
+	if plan.InputWidth == plan.SourceWidth && plan.InputHeight == plan.SourceHeight {
+		return ""
+	}
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease:force_divisible_by=2", plan.InputWidth, plan.InputHeight)
+}
+
+// planResolution decides how the source reaches the output size: the pre-scale,
+// the model passes and the resampling applied to their output. Without a
+// target the model scale is ScaleMultiplier, with one it is the smallest scale
+// of the model reaching the target so the last step only ever downsamples.
+// Chained passes keep their own scales, a target only sets the resampling.
+func (u *videoUpscalerUsecase) planResolution(params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) (datatransfers.ResolutionPlan, error) {
+	plan := datatransfers.ResolutionPlan{
+		SourceWidth:  videoMetadata.Width,
+		SourceHeight: videoMetadata.Height,
+	}
+	if plan.SourceWidth <= 0 || plan.SourceHeight <= 0 {
+		return plan, fmt.Errorf("the video size is unknown")
+	}
+	if err := ValidatePreScale(params.PreScale); err != nil {
+		return plan, err
+	}
+	if err := ValidatePasses(params.Passes); err != nil {
+		return plan, err
+	}
+	passes := slices.Clone(requestPasses(params))
+	applyPreScale(&plan, params.PreScale, passes[0].Model)
+
+	// contentWidth x contentHeight is the picture inside the output, smaller than it when padded and larger when cropped
+	target := params.Target
+	var contentWidth, contentHeight int
+	if target.Mode != constants.TargetModeNone {
+		if err := ValidateTarget(target); err != nil {
+			return plan, err
+		}
+
+		sourceWidth, sourceHeight := float64(plan.SourceWidth), float64(plan.SourceHeight)
+		switch target.Mode {
+		case constants.TargetModeHeight:
+			contentWidth, contentHeight = even(sourceWidth*float64(target.Height)/sourceHeight), even(float64(target.Height))
+			plan.OutputWidth, plan.OutputHeight = contentWidth, contentHeight
+		case constants.TargetModeFit:
+			ratio := math.Min(float64(target.Width)/sourceWidth, float64(target.Height)/sourceHeight)
+			contentWidth, contentHeight = even(sourceWidth*ratio), even(sourceHeight*ratio)
+			plan.OutputWidth, plan.OutputHeight = contentWidth, contentHeight
+		case constants.TargetModeExact:
+			ratio := math.Min(float64(target.Width)/sourceWidth, float64(target.Height)/sourceHeight)
+			if target.Fill == constants.TargetFillCrop {
+				ratio = math.Max(float64(target.Width)/sourceWidth, float64(target.Height)/sourceHeight)
+			}
+			contentWidth, contentHeight = even(sourceWidth*ratio), even(sourceHeight*ratio)
+			plan.OutputWidth, plan.OutputHeight = even(float64(target.Width)), even(float64(target.Height))
+		}
+
+		if len(params.Passes) == 0 {
+			scales := slices.Sorted(slices.Values(engine.ScalesFor(u.engineFor(params.Model), params.Model)))
+			passes[0].Scale = scales[len(scales)-1]
+			for _, scale := range scales {
+				if plan.InputWidth*scale >= contentWidth && plan.InputHeight*scale >= contentHeight {
+					passes[0].Scale = scale
+					break
+				}
+			}
+		}
+	}
+
+	// Every pass is checked before the first frame is extracted
+	if err := u.resolvePassScales(passes); err != nil {
+		return plan, err
+	}
+	plan.ModelScale = 1
+	for _, pass := range passes {
+		plan.ModelScale *= pass.Scale
+	}
+	plan.Passes = passes
+	plan.UpscaledWidth, plan.UpscaledHeight = plan.InputWidth*plan.ModelScale, plan.InputHeight*plan.ModelScale
+
+	if target.Mode == constants.TargetModeNone {
+		plan.OutputWidth, plan.OutputHeight = plan.UpscaledWidth, plan.UpscaledHeight
+	} else {
+		if plan.UpscaledWidth < contentWidth || plan.UpscaledHeight < contentHeight {
+			u.logger.Warning(fmt.Sprintf("⚠️ %s tops out at %dx%d, the last %dx%d step is a plain resample", describePasses(passes), plan.UpscaledWidth, plan.UpscaledHeight, contentWidth, contentHeight))
+		}
+
+		if plan.UpscaledWidth != contentWidth || plan.UpscaledHeight != contentHeight {
+			plan.FinalFilter = fmt.Sprintf("scale=%d:%d:flags=lanczos", contentWidth, contentHeight)
+		}
+		if contentWidth < plan.OutputWidth || contentHeight < plan.OutputHeight {
+			plan.FinalFilter += fmt.Sprintf(",pad=%d:%d:(ow-iw)/2:(oh-ih)/2", plan.OutputWidth, plan.OutputHeight)
+		} else if contentWidth > plan.OutputWidth || contentHeight > plan.OutputHeight {
+			plan.FinalFilter += fmt.Sprintf(",crop=%d:%d", plan.OutputWidth, plan.OutputHeight)
+		}
+		plan.FinalFilter = strings.TrimPrefix(plan.FinalFilter, ",")
+	}
+
+	if max(plan.UpscaledWidth, plan.UpscaledHeight, plan.OutputWidth, plan.OutputHeight) > constants.MaxFrameDimension {
+		return plan, fmt.Errorf("%s upscales %dx%d to %dx%d, frames are limited to %dpx per side", describePasses(passes), plan.InputWidth, plan.InputHeight, plan.UpscaledWidth, plan.UpscaledHeight, constants.MaxFrameDimension)
+	}
+	return plan, nil
+}
+
+// PlanResolution plans the resolution of a request before it is queued, only the
+// size of the input is probed. The job plans again when it starts, in case the
+// engine falls back to the ffmpeg scaler.
+func (u *videoUpscalerUsecase) PlanResolution(ctx context.Context, params *datatransfers.VideoUpscalerRequest) (datatransfers.ResolutionPlan, error) {
+	cmd := exec.CommandContext(ctx, config.Paths.FFprobePath, "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=width,height", "-of", "json", params.TempFilePath)
+	utils.HideWindowsCMD(cmd)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return datatransfers.ResolutionPlan{}, fmt.Errorf("failed to read the video size of %s: %v", params.InputFullFileName, err)
+	}
+
+	var probe models.FFProbeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return datatransfers.ResolutionPlan{}, err
+	}
+	if len(probe.Streams) == 0 {
+		return datatransfers.ResolutionPlan{}, fmt.Errorf("no video stream found in %s", params.InputFullFileName)
+	}
+
+	return u.planResolution(params, &datatransfers.FFProbeStreamsMetadataResponse{
+		Width:  probe.Streams[0].Width,
+		Height: probe.Streams[0].Height,
+	})
+}
+
+// resolvePassScales checks the scale of every pass against the scales of its
+// model, a scale of 0 becomes the largest one.
+func (u *videoUpscalerUsecase) resolvePassScales(passes []datatransfers.UpscalePass) error {
+	for i, pass := range passes {
+		scales := slices.Sorted(slices.Values(engine.ScalesFor(u.engineFor(pass.Model), pass.Model)))
+		if pass.Scale == 0 {
+			passes[i].Scale = scales[len(scales)-1]
+		}
+		if !slices.Contains(scales, passes[i].Scale) {
+			return fmt.Errorf("model %s can't upscale %dx, supported: %v", pass.Model, passes[i].Scale, scales)
+		}
+	}
+	return nil
+}
+
+// ValidateScales checks before a request is queued that every model supports
+// its scale. A single model with a target gets its scale from the target.
+func (u *videoUpscalerUsecase) ValidateScales(params *datatransfers.VideoUpscalerRequest) error {
+	if err := ValidatePasses(params.Passes); err != nil {
+		return err
+	}
+	if len(params.Passes) == 0 && params.Target.Mode != constants.TargetModeNone {
+		return nil
+	}
+	return u.resolvePassScales(slices.Clone(requestPasses(params)))
+}
+
+// describePlan formats a plan for the logs, e.g.
+// "1920x1080 → pre-scale 0.50x (factor) 960x540 → model 2x 1920x1080 → output 1920x1080".
+func describePlan(plan datatransfers.ResolutionPlan) string {
+	description := fmt.Sprintf("%dx%d", plan.SourceWidth, plan.SourceHeight)
+	if plan.InputWidth != plan.SourceWidth || plan.InputHeight != plan.SourceHeight {
+		description += fmt.Sprintf(" → pre-scale %.2fx (%s) %dx%d", plan.PreScaleFactor, plan.PreScalePolicy, plan.InputWidth, plan.InputHeight)
+	}
+	if len(plan.Passes) > 1 {
+		description += fmt.Sprintf(" → %s = %dx %dx%d", describePasses(plan.Passes), plan.ModelScale, plan.UpscaledWidth, plan.UpscaledHeight)
+	} else {
+		description += fmt.Sprintf(" → model %dx %dx%d", plan.ModelScale, plan.UpscaledWidth, plan.UpscaledHeight)
+	}
+	if plan.FinalFilter != "" {
+		description += fmt.Sprintf(" → %s", plan.FinalFilter)
+	}
+	return description + fmt.Sprintf(" → output %dx%d", plan.OutputWidth, plan.OutputHeight)
+}
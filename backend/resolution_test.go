@@ -0,0 +1,259 @@
+package backend
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/engine"
+)
+
+// newFakeUpscaler returns the usecase backed by the fake engine, Real-ESRGAN is
+// registered after it for its model scales.
+func newFakeUpscaler(t *testing.T) *videoUpscalerUsecase {
+	t.Helper()
+	u := NewVideoUpscalerWithEngine(newTestLogger(t), &sync.Map{}, engine.NewFake()).(*videoUpscalerUsecase)
+	u.engines = append(u.engines, engine.NewRealEsrgan())
+	return u
+}
+
+func TestPlanResolution(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        datatransfers.VideoUpscalerRequest
+		width, height int
+		wantInput     [2]int
+		wantScale     int
+		wantUpscaled  [2]int
+		wantFilter    string
+		wantOutput    [2]int
+		wantErr       bool
+	}{
+		{
+			name:   "scale multiplier",
+			params: datatransfers.VideoUpscalerRequest{ScaleMultiplier: 2},
+			width:  640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 2, wantUpscaled: [2]int{1280, 720}, wantOutput: [2]int{1280, 720},
+		},
+		{
+			name:  "largest scale by default",
+			width: 640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 4, wantUpscaled: [2]int{2560, 1440}, wantOutput: [2]int{2560, 1440},
+		},
+		{
+			name:   "target height picks the smallest scale reaching it",
+			params: datatransfers.VideoUpscalerRequest{Target: datatransfers.TargetResolution{Mode: constants.TargetModeHeight, Height: 1080}},
+			width:  640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 3, wantUpscaled: [2]int{1920, 1080}, wantOutput: [2]int{1920, 1080},
+		},
+		{
+			name:   "fit downsamples the model output",
+			params: datatransfers.VideoUpscalerRequest{Target: datatransfers.TargetResolution{Mode: constants.TargetModeFit, Width: 1000, Height: 1000}},
+			width:  640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 2, wantUpscaled: [2]int{1280, 720},
+			wantFilter: "scale=1000:562:flags=lanczos", wantOutput: [2]int{1000, 562},
+		},
+		{
+			name:   "exact pads",
+			params: datatransfers.VideoUpscalerRequest{Target: datatransfers.TargetResolution{Mode: constants.TargetModeExact, Width: 1000, Height: 1000}},
+			width:  640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 2, wantUpscaled: [2]int{1280, 720},
+			wantFilter: "scale=1000:562:flags=lanczos,pad=1000:1000:(ow-iw)/2:(oh-ih)/2", wantOutput: [2]int{1000, 1000},
+		},
+		{
+			name:   "exact crops",
+			params: datatransfers.VideoUpscalerRequest{Target: datatransfers.TargetResolution{Mode: constants.TargetModeExact, Width: 1000, Height: 1000, Fill: constants.TargetFillCrop}},
+			width:  640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 3, wantUpscaled: [2]int{1920, 1080},
+			wantFilter: "scale=1778:1000:flags=lanczos,crop=1000:1000", wantOutput: [2]int{1000, 1000},
+		},
+		{
+			name:   "pre-scale factor",
+			params: datatransfers.VideoUpscalerRequest{ScaleMultiplier: 2, PreScale: datatransfers.PreScaleOptions{Policy: constants.PreScaleFactor, Factor: 0.5}},
+			width:  640, height: 360,
+			wantInput: [2]int{320, 180}, wantScale: 2, wantUpscaled: [2]int{640, 360}, wantOutput: [2]int{640, 360},
+		},
+		{
+			name:   "pre-scale max dimension",
+			params: datatransfers.VideoUpscalerRequest{ScaleMultiplier: 2, PreScale: datatransfers.PreScaleOptions{Policy: constants.PreScaleMaxDimension, MaxDimension: 320}},
+			width:  640, height: 360,
+			wantInput: [2]int{320, 180}, wantScale: 2, wantUpscaled: [2]int{640, 360}, wantOutput: [2]int{640, 360},
+		},
+		{
+			name:   "pre-scale keeps the aspect ratio",
+			params: datatransfers.VideoUpscalerRequest{ScaleMultiplier: 2, PreScale: datatransfers.PreScaleOptions{Policy: constants.PreScaleFactor, Factor: 0.37}},
+			width:  1920, height: 1080,
+			wantInput: [2]int{708, 398}, wantScale: 2, wantUpscaled: [2]int{1416, 796}, wantOutput: [2]int{1416, 796},
+		},
+		{
+			name:   "auto keeps the source size for other models",
+			params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus"},
+			width:  1280, height: 720,
+			wantInput: [2]int{1280, 720}, wantScale: 4, wantUpscaled: [2]int{5120, 2880}, wantOutput: [2]int{5120, 2880},
+		},
+		{
+			name:   "auto caps anime models at 720p",
+			params: datatransfers.VideoUpscalerRequest{Model: "realesr-animevideov3", ScaleMultiplier: 2},
+			width:  1920, height: 1080,
+			wantInput: [2]int{1280, 720}, wantScale: 2, wantUpscaled: [2]int{2560, 1440}, wantOutput: [2]int{2560, 1440},
+		},
+		{
+			name:   "auto leaves small anime sources alone",
+			params: datatransfers.VideoUpscalerRequest{Model: "realesr-animevideov3", ScaleMultiplier: 2},
+			width:  640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 2, wantUpscaled: [2]int{1280, 720}, wantOutput: [2]int{1280, 720},
+		},
+		{
+			name:   "chained passes",
+			params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: engine.FakeModel, Scale: 2}, {Model: engine.FakeModel, Scale: 3}}},
+			width:  640, height: 360,
+			wantInput: [2]int{640, 360}, wantScale: 6, wantUpscaled: [2]int{3840, 2160}, wantOutput: [2]int{3840, 2160},
+		},
+		{
+			name:   "unsupported scale",
+			params: datatransfers.VideoUpscalerRequest{ScaleMultiplier: 5},
+			width:  640, height: 360,
+			wantErr: true,
+		},
+		{
+			name:   "model with a fixed scale",
+			params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus", ScaleMultiplier: 2},
+			width:  640, height: 360,
+			wantErr: true,
+		},
+		{
+			name:   "frames too large",
+			params: datatransfers.VideoUpscalerRequest{ScaleMultiplier: 4},
+			width:  7680, height: 4320,
+			wantErr: true,
+		},
+		{name: "unknown size", wantErr: true},
+	}
+
+	u := newFakeUpscaler(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			if params.Model == "" {
+				params.Model = engine.FakeModel
+			}
+
+			plan, err := u.planResolution(&params, &datatransfers.FFProbeStreamsMetadataResponse{Width: tt.width, Height: tt.height})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planResolution() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := [][2]int{{plan.InputWidth, plan.InputHeight}, {plan.UpscaledWidth, plan.UpscaledHeight}, {plan.OutputWidth, plan.OutputHeight}}
+			want := [][2]int{tt.wantInput, tt.wantUpscaled, tt.wantOutput}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("plan %s, want input %v, upscaled %v, output %v", describePlan(plan), tt.wantInput, tt.wantUpscaled, tt.wantOutput)
+					break
+				}
+			}
+			if plan.ModelScale != tt.wantScale || plan.FinalFilter != tt.wantFilter {
+				t.Errorf("scale = %d, filter = %q, want %d, %q", plan.ModelScale, plan.FinalFilter, tt.wantScale, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func TestFitAspect(t *testing.T) {
+	tests := []struct {
+		source, box, want [2]int
+	}{
+		{source: [2]int{1920, 1080}, box: [2]int{960, 540}, want: [2]int{960, 540}},
+		{source: [2]int{1920, 1080}, box: [2]int{710, 400}, want: [2]int{708, 398}},
+		{source: [2]int{1440, 1080}, box: [2]int{1000, 1000}, want: [2]int{1000, 750}},
+		{source: [2]int{720, 1280}, box: [2]int{301, 535}, want: [2]int{298, 530}},
+	}
+
+	for _, tt := range tests {
+		width, height := fitAspect(tt.source[0], tt.source[1], tt.box[0], tt.box[1])
+		if got := [2]int{width, height}; got != tt.want {
+			t.Errorf("fitAspect(%v, %v) = %v, want %v", tt.source, tt.box, got, tt.want)
+			continue
+		}
+		// ffmpeg given the fitted size keeps it
+		if again, _ := fitAspect(tt.source[0], tt.source[1], width, height); again != width {
+			t.Errorf("fitAspect(%v, %v) is not stable", tt.source, tt.want)
+		}
+	}
+}
+
+func TestValidateScales(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  datatransfers.VideoUpscalerRequest
+		wantErr bool
+	}{
+		{name: "model scale", params: datatransfers.VideoUpscalerRequest{Model: "realesr-animevideov3", ScaleMultiplier: 3}},
+		{name: "fixed scale model", params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus", ScaleMultiplier: 4}},
+		{name: "largest scale", params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus"}},
+		{name: "target picks the scale", params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus", ScaleMultiplier: 2, Target: datatransfers.TargetResolution{Mode: constants.TargetModeHeight, Height: 1080}}},
+		{name: "passes", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: "realesr-animevideov3-x2"}, {Model: "realesrgan-x4plus", Scale: 4}}}},
+		{name: "unsupported model scale", params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus", ScaleMultiplier: 2}, wantErr: true},
+		{name: "unsupported pass scale", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: "realesr-animevideov3-x2"}, {Model: "realesrgan-x4plus", Scale: 2}}}, wantErr: true},
+		{name: "pass without model", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Scale: 2}}}, wantErr: true},
+	}
+
+	u := NewVideoUpscaler(newTestLogger(t), &sync.Map{}).(*videoUpscalerUsecase)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passes := slices.Clone(tt.params.Passes)
+			if err := u.ValidateScales(&tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateScales() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(tt.params.Passes, passes) {
+				t.Errorf("ValidateScales() changed the passes to %v", tt.params.Passes)
+			}
+		})
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  datatransfers.VideoUpscalerRequest
+		wantErr bool
+	}{
+		{name: "real-esrgan model", params: datatransfers.VideoUpscalerRequest{Model: "realesr-animevideov3"}},
+		{name: "ffmpeg model", params: datatransfers.VideoUpscalerRequest{Model: engine.FFmpegModelLanczos}},
+		{name: "passes", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: "realesr-animevideov3-x2"}, {Model: engine.FFmpegModelSpline}}}},
+		{name: "typo", params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plsu", ScaleMultiplier: 4}, wantErr: true},
+		{name: "no model", params: datatransfers.VideoUpscalerRequest{ScaleMultiplier: 2}, wantErr: true},
+		{name: "typo in a pass", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: "realesr-animevideov3-x2"}, {Model: "realesrgan-x5plus"}}}, wantErr: true},
+	}
+
+	u := NewVideoUpscaler(newTestLogger(t), &sync.Map{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := u.ValidateModels(&tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateModels() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanResolutionProbesInput(t *testing.T) {
+	requireFFmpeg(t)
+
+	params := &datatransfers.VideoUpscalerRequest{
+		TempFilePath: numberedClip(t, 5, false),
+		Model:        engine.FakeModel,
+		Target:       datatransfers.TargetResolution{Mode: constants.TargetModeHeight, Height: 100},
+	}
+	plan, err := newFakeUpscaler(t).PlanResolution(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.SourceWidth != 32 || plan.ModelScale != 4 || plan.OutputWidth != 100 || plan.OutputHeight != 100 {
+		t.Errorf("plan %s, want 32x32 upscaled 4x to 100x100", describePlan(plan))
+	}
+}
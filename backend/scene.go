@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+var (
+	sceneFramePattern = regexp.MustCompile(`\bframe:(\d+)\s`)
+	sceneScorePattern = regexp.MustCompile(`lavfi\.scene_score=(\d+(?:\.\d+)?)`)
+)
+
+// ValidateSceneThreshold checks the scene threshold of a request before it is queued.
+func ValidateSceneThreshold(threshold float64) error {
+	if threshold < 0 || threshold >= 1 {
+		return fmt.Errorf("scene threshold must be between 0 and 1, e.g. 0.3")
+	}
+	return nil
+}
+
+// planBatches splits [StartFrame, EndFrame) into the batches encoded one by one.
+// Batches end on a scene cut when one is found between the min and max batch
+// sizes, so the joins of the separately encoded videos land where the picture
+// changes anyway. It returns the detected cuts along with the batches.
+func (u *videoUpscalerUsecase) planBatches(ctx context.Context, params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) ([]int, []datatransfers.FrameRange, error) {
+	if err := ValidateSceneThreshold(params.SceneThreshold); err != nil {
+		return nil, nil, err
+	}
+
+	var cuts []int
+	if !params.FixedBatches && params.EndFrame-params.StartFrame > constants.MaxBatchFrames {
+		u.logger.Info("🎬 Detecting scene cuts")
+		detected, err := u.detectSceneCuts(ctx, params, videoMetadata)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err != nil {
+			u.logger.Warning(fmt.Sprintf("⚠️ Scene detection failed (%v), cutting batches every %d frames", err, constants.MaxBatchFrames))
+		} else {
+			cuts = detected
+			u.logger.Info(fmt.Sprintf("🎬 Found %d scene cuts", len(cuts)))
+		}
+	}
+
+	return cuts, splitBatches(params.StartFrame, params.EndFrame, cuts, constants.MinBatchFrames, constants.MaxBatchFrames), nil
+}
+
+// splitBatches cuts [start, end) into batches of at most maxFrames, each one
+// ending on the last of the sorted cuts leaving it at least minFrames long.
+func splitBatches(start, end int, cuts []int, minFrames, maxFrames int) []datatransfers.FrameRange {
+	var batches []datatransfers.FrameRange
+	for start < end {
+		batchEnd := min(start+maxFrames, end)
+		if batchEnd < end {
+			// The last cut at or before batchEnd, a cut starts the next batch
+			if i := sort.SearchInts(cuts, batchEnd+1) - 1; i >= 0 && cuts[i] >= start+minFrames {
+				batchEnd = cuts[i]
+			}
+		}
+		batches = append(batches, datatransfers.FrameRange{StartFrame: start, EndFrame: batchEnd - 1})
+		start = batchEnd
+	}
+	return batches
+}
+
+// detectSceneCuts decodes the frame range once and returns the frames starting
+// a new scene, the ones whose scene score is above the threshold. Every frame
+// goes through select so the frame counter of metadata is the frame index.
+func (u *videoUpscalerUsecase) detectSceneCuts(ctx context.Context, params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) ([]int, error) {
+	threshold := params.SceneThreshold
+	if threshold == 0 {
+		threshold = constants.DefaultSceneThreshold
+	}
+
+	var filters []string
+	cmdArgs := []string{"-hide_banner", "-nostats"}
+	if params.StartFrame > 0 {
+		if position, ok := seekPosition(params.StartFrame, videoMetadata); ok {
+			cmdArgs = append(cmdArgs, "-accurate_seek", "-ss", position)
+		} else {
+			filters = append(filters, fmt.Sprintf("select=gte(n\\,%d)", params.StartFrame))
+		}
+	}
+	filters = append(filters, "select=gte(scene\\,0)", "metadata=print:key=lavfi.scene_score")
+
+	cmdArgs = append(cmdArgs,
+		"-i", params.TempFilePath,
+		"-map", "0:v:0",
+		"-vf", strings.Join(filters, ","),
+		"-frames:v", strconv.Itoa(params.EndFrame-params.StartFrame),
+		"-f", "null", "-",
+	)
+
+	cmd := exec.CommandContext(ctx, config.Paths.FFmpegPath, cmdArgs...)
+	utils.HideWindowsCMD(cmd)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// metadata prints "frame:N pts:... pts_time:..." followed by "lavfi.scene_score=S"
+	var cuts []int
+	frame := -1
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := sceneFramePattern.FindStringSubmatch(line); match != nil {
+			frame, _ = strconv.Atoi(match[1])
+			continue
+		}
+		match := sceneScorePattern.FindStringSubmatch(line)
+		if match == nil || frame <= 0 {
+			continue
+		}
+		if score, _ := strconv.ParseFloat(match[1], 64); score > threshold {
+			cuts = append(cuts, params.StartFrame+frame)
+		}
+	}
+	io.Copy(io.Discard, stderr) // a line too long for the scanner must not block ffmpeg
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg scene detection failed: %v", err)
+	}
+	return cuts, nil
+}
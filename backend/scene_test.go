@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+func TestSplitBatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+		cuts       []int
+		want       []datatransfers.FrameRange
+	}{
+		{name: "empty range", start: 5, end: 5},
+		{
+			name: "no cuts",
+			end:  10,
+			want: []datatransfers.FrameRange{{StartFrame: 0, EndFrame: 3}, {StartFrame: 4, EndFrame: 7}, {StartFrame: 8, EndFrame: 9}},
+		},
+		{
+			name:  "trimmed start",
+			start: 10, end: 18,
+			want: []datatransfers.FrameRange{{StartFrame: 10, EndFrame: 13}, {StartFrame: 14, EndFrame: 17}},
+		},
+		{
+			name: "cut ends the batch early",
+			end:  10, cuts: []int{3},
+			want: []datatransfers.FrameRange{{StartFrame: 0, EndFrame: 2}, {StartFrame: 3, EndFrame: 6}, {StartFrame: 7, EndFrame: 9}},
+		},
+		{
+			name: "cut on the batch size",
+			end:  8, cuts: []int{4},
+			want: []datatransfers.FrameRange{{StartFrame: 0, EndFrame: 3}, {StartFrame: 4, EndFrame: 7}},
+		},
+		{
+			name: "cut too close to the batch start",
+			end:  10, cuts: []int{1, 5},
+			want: []datatransfers.FrameRange{{StartFrame: 0, EndFrame: 3}, {StartFrame: 4, EndFrame: 7}, {StartFrame: 8, EndFrame: 9}},
+		},
+		{
+			name: "last cut of several",
+			end:  10, cuts: []int{2, 3, 9},
+			want: []datatransfers.FrameRange{{StartFrame: 0, EndFrame: 2}, {StartFrame: 3, EndFrame: 6}, {StartFrame: 7, EndFrame: 9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitBatches(tt.start, tt.end, tt.cuts, 2, 4); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitBatches(%d, %d, %v) = %v, want %v", tt.start, tt.end, tt.cuts, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+const (
+	fileName      = "settings.json"
+	exportVersion = 1
+)
+
+var ErrPresetNotFound = errors.New("preset not found")
+
+// builtinPresets are offered until the user edits or deletes them.
+var builtinPresets = []datatransfers.Preset{
+	{
+		Name:            "Anime 2x fast",
+		Model:           "realesr-animevideov3",
+		ScaleMultiplier: 2,
+		Encoder:         datatransfers.EncoderOptions{Codec: constants.DefaultVideoEncoder, CRF: crf(20)},
+	},
+	{
+		Name:            "Live action 4x archive",
+		Model:           "realesrgan-x4plus",
+		ScaleMultiplier: 4,
+		Encoder:         datatransfers.EncoderOptions{Codec: constants.DefaultVideoEncoder, CRF: crf(14)},
+		Container:       "mkv",
+	},
+}
+
+// Defaults returns the settings used before anything is saved.
+func Defaults() datatransfers.Settings {
+	return datatransfers.Settings{
+		Encoder:   datatransfers.EncoderOptions{Codec: constants.DefaultVideoEncoder, CRF: crf(constants.DefaultCRF)},
+		Container: constants.DefaultContainer,
+		Presets:   append([]datatransfers.Preset(nil), builtinPresets...),
+	}
+}
+
+// DefaultPath returns the settings file in the user config dir.
+func DefaultPath(appName string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, appName, fileName), nil
+}
+
+// Store keeps the settings in memory and writes them back to a JSON file on
+// every change.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	settings datatransfers.Settings
+}
+
+// NewStore loads the settings file at path over the defaults, so settings missing
+// from the file keep their default. A missing file gives the defaults, an
+// unreadable one gives the defaults and an error, the store is usable either way.
+func NewStore(path string) (*Store, error) {
+	store := &Store{path: path, settings: Defaults()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return store, fmt.Errorf("failed to read settings: %v", err)
+	}
+
+	// The presets are decoded into an empty list, decoding over the built-in ones
+	// would merge them with the saved presets at the same index
+	settings := Defaults()
+	settings.Presets = nil
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return store, fmt.Errorf("failed to parse settings %s: %v", path, err)
+	}
+	if settings.Presets == nil {
+		settings.Presets = Defaults().Presets
+	}
+	store.settings = settings
+	return store, nil
+}
+
+// Get returns a copy of the settings.
+func (s *Store) Get() datatransfers.Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return clone(s.settings)
+}
+
+// Update replaces the settings. Presets are managed with SavePreset, DeletePreset
+// and ImportPresets, the ones in settings are ignored.
+func (s *Store) Update(settings datatransfers.Settings) error {
+	if settings.Concurrency < 0 {
+		return fmt.Errorf("concurrency can't be negative")
+	}
+	if settings.Encoder.CRF != nil && *settings.Encoder.CRF < 0 {
+		return fmt.Errorf("crf can't be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := clone(settings)
+	updated.Presets = s.settings.Presets
+	return s.saveLocked(updated)
+}
+
+// Presets returns every preset.
+func (s *Store) Presets() []datatransfers.Preset {
+	return s.Get().Presets
+}
+
+// Preset returns the preset with the given name.
+func (s *Store) Preset(name string) (datatransfers.Preset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := indexOf(s.settings.Presets, name); i >= 0 {
+		return s.settings.Presets[i], nil
+	}
+	return datatransfers.Preset{}, fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+}
+
+// SavePreset creates a preset when originalName is empty, otherwise it replaces
+// the preset called originalName, which may be renamed.
+func (s *Store) SavePreset(originalName string, preset datatransfers.Preset) error {
+	preset.Name = strings.TrimSpace(preset.Name)
+	if err := validatePreset(preset); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := clone(s.settings)
+	existing := indexOf(updated.Presets, preset.Name)
+
+	if originalName == "" {
+		if existing >= 0 {
+			return fmt.Errorf("a preset named %s already exists", preset.Name)
+		}
+		updated.Presets = append(updated.Presets, preset)
+		return s.saveLocked(updated)
+	}
+
+	i := indexOf(updated.Presets, originalName)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrPresetNotFound, originalName)
+	}
+	if existing >= 0 && existing != i {
+		return fmt.Errorf("a preset named %s already exists", preset.Name)
+	}
+	updated.Presets[i] = preset
+	return s.saveLocked(updated)
+}
+
+// DeletePreset removes the preset with the given name.
+func (s *Store) DeletePreset(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := clone(s.settings)
+	i := indexOf(updated.Presets, name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+	}
+	updated.Presets = append(updated.Presets[:i], updated.Presets[i+1:]...)
+	return s.saveLocked(updated)
+}
+
+// ExportPresets writes the named presets, all of them when names is empty, to path.
+func (s *Store) ExportPresets(path string, names []string) error {
+	presets := s.Presets()
+	if len(names) > 0 {
+		selected := make([]datatransfers.Preset, 0, len(names))
+		for _, name := range names {
+			i := indexOf(presets, name)
+			if i < 0 {
+				return fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+			}
+			selected = append(selected, presets[i])
+		}
+		presets = selected
+	}
+
+	data, err := json.MarshalIndent(datatransfers.PresetExport{Version: exportVersion, Presets: presets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ImportPresets adds the presets exported to path, replacing the ones with the
+// same name, and returns them.
+func (s *Store) ImportPresets(path string) ([]datatransfers.Preset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var export datatransfers.PresetExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid preset file: %v", err)
+	}
+	if export.Version > exportVersion {
+		return nil, fmt.Errorf("preset file version %d is newer than supported version %d", export.Version, exportVersion)
+	}
+	for i := range export.Presets {
+		export.Presets[i].Name = strings.TrimSpace(export.Presets[i].Name)
+		if err := validatePreset(export.Presets[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := clone(s.settings)
+	for _, preset := range export.Presets {
+		if i := indexOf(updated.Presets, preset.Name); i >= 0 {
+			updated.Presets[i] = preset
+		} else {
+			updated.Presets = append(updated.Presets, preset)
+		}
+	}
+	if err := s.saveLocked(updated); err != nil {
+		return nil, err
+	}
+	return export.Presets, nil
+}
+
+// saveLocked writes settings to disk atomically and makes them current.
+func (s *Store) saveLocked(settings datatransfers.Settings) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create settings dir: %v", err)
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write settings: %v", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to write settings: %v", err)
+	}
+
+	s.settings = settings
+	return nil
+}
+
+// ApplyPreset copies the knobs of preset into request.
+func ApplyPreset(request *datatransfers.VideoUpscalerRequest, preset datatransfers.Preset) {
+	request.Model = preset.Model
+	request.ScaleMultiplier = preset.ScaleMultiplier
+	request.Passes = append([]datatransfers.UpscalePass(nil), preset.Passes...)
+	request.Target = preset.Target
+	request.PreScale = preset.PreScale
+	request.TileSize = preset.TileSize
+	request.VideoFPS = preset.VideoFPS
+	request.FrameRateMode = preset.FrameRateMode
+	request.Streaming = preset.Streaming
+	request.StreamBufferFrames = preset.StreamBufferFrames
+	request.Encoder = preset.Encoder
+	request.Audio = preset.Audio
+	request.Container = preset.Container
+	request.Concurrency = preset.Concurrency
+}
+
+// ApplyDefaults fills the knobs left empty in request from settings.
+func ApplyDefaults(request *datatransfers.VideoUpscalerRequest, settings datatransfers.Settings) {
+	if request.Encoder.Codec == "" {
+		request.Encoder = settings.Encoder
+	}
+	if request.Audio == (datatransfers.AudioOptions{}) {
+		request.Audio = settings.Audio
+	}
+	if request.Container == "" {
+		request.Container = settings.Container
+	}
+	if request.Concurrency == 0 {
+		request.Concurrency = settings.Concurrency
+	}
+}
+
+func validatePreset(preset datatransfers.Preset) error {
+	if preset.Name == "" {
+		return fmt.Errorf("preset name is required")
+	}
+	if len(preset.Passes) > 0 {
+		for i, pass := range preset.Passes {
+			if pass.Model == "" || pass.Scale < 0 {
+				return fmt.Errorf("preset %s: pass %d needs a model and a scale", preset.Name, i+1)
+			}
+		}
+	} else if preset.Model == "" || (preset.ScaleMultiplier <= 0 && preset.Target.Mode == constants.TargetModeNone) {
+		return fmt.Errorf("preset %s: model and scale or target are required", preset.Name)
+	}
+	if preset.Concurrency < 0 || (preset.Encoder.CRF != nil && *preset.Encoder.CRF < 0) {
+		return fmt.Errorf("preset %s: concurrency and crf can't be negative", preset.Name)
+	}
+	return nil
+}
+
+func indexOf(presets []datatransfers.Preset, name string) int {
+	for i, preset := range presets {
+		if preset.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// crf returns a pointer to value, for the CRF of literal encoder options.
+func crf(value int) *int {
+	return &value
+}
+
+func clone(settings datatransfers.Settings) datatransfers.Settings {
+	settings.Presets = append([]datatransfers.Preset(nil), settings.Presets...)
+	if settings.ToolPaths != nil {
+		toolPaths := make(map[string]string, len(settings.ToolPaths))
+		for tool, path := range settings.ToolPaths {
+			toolPaths[tool] = path
+		}
+		settings.ToolPaths = toolPaths
+	}
+	return settings
+}
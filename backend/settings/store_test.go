@@ -0,0 +1,75 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+)
+
+func TestNewStoreLoadsPartialFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		wantCodec     string
+		wantCRF       int
+		wantContainer string
+		wantPresets   []string
+	}{
+		{
+			name:          "only the output folder",
+			file:          `{"outputFolder": "/videos"}`,
+			wantCodec:     constants.DefaultVideoEncoder,
+			wantCRF:       constants.DefaultCRF,
+			wantContainer: constants.DefaultContainer,
+			wantPresets:   []string{"Anime 2x fast", "Live action 4x archive"},
+		},
+		{
+			name:          "partial encoder",
+			file:          `{"encoder": {"codec": "libx265"}, "container": "mkv"}`,
+			wantCodec:     "libx265",
+			wantCRF:       constants.DefaultCRF,
+			wantContainer: "mkv",
+			wantPresets:   []string{"Anime 2x fast", "Live action 4x archive"},
+		},
+		{
+			name:          "saved presets replace the built-in ones",
+			file:          `{"presets": [{"name": "Mine", "model": "realesrgan-x4plus", "scaleMultiplier": 4}, {"name": "Mine too", "model": "realesrgan-x4plus", "scaleMultiplier": 2}]}`,
+			wantCodec:     constants.DefaultVideoEncoder,
+			wantCRF:       constants.DefaultCRF,
+			wantContainer: constants.DefaultContainer,
+			wantPresets:   []string{"Mine", "Mine too"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "settings.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := NewStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			settings := store.Get()
+			if settings.Encoder.Codec != tt.wantCodec || settings.Encoder.CRF == nil || *settings.Encoder.CRF != tt.wantCRF || settings.Container != tt.wantContainer {
+				t.Errorf("encoder = %+v, container = %q, want %s crf %d in %s", settings.Encoder, settings.Container, tt.wantCodec, tt.wantCRF, tt.wantContainer)
+			}
+
+			var names []string
+			for _, preset := range settings.Presets {
+				names = append(names, preset.Name)
+				if preset.Name != "Live action 4x archive" && preset.Container != "" {
+					t.Errorf("preset %s got container %q from a built-in one", preset.Name, preset.Container)
+				}
+			}
+			if !slices.Equal(names, tt.wantPresets) {
+				t.Errorf("presets = %v, want %v", names, tt.wantPresets)
+			}
+		})
+	}
+}
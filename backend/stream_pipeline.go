@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/engine"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+const defaultStreamBufferFrames = 8
+
+// ValidateStreaming checks before a request is queued that a streaming request
+// runs a single model whose engine can upscale frames in memory.
+func (u *videoUpscalerUsecase) ValidateStreaming(params *datatransfers.VideoUpscalerRequest) error {
+	if !params.Streaming {
+		return nil
+	}
+	passes := requestPasses(params)
+	if len(passes) > 1 {
+		return fmt.Errorf("chained passes cannot stream frames, turn streaming off")
+	}
+
+	upscaleEngine := u.engineFor(passes[0].Model)
+	if _, ok := upscaleEngine.(engine.StreamEngine); !ok {
+		return fmt.Errorf("%s cannot stream frames, turn streaming off or pick an ffmpeg model", upscaleEngine.Capabilities().Name)
+	}
+	return nil
+}
+
+// upscaleVideoStreaming decodes the input to rawvideo on a pipe, hands every
+// frame to the engine in memory and pipes the result straight into the encoder,
+// so no intermediate png is ever written to disk.
+func (u *videoUpscalerUsecase) upscaleVideoStreaming(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+	startTime := time.Now()
+
+	u.logger.Info(fmt.Sprintf("🚀 Streaming upscale: %s with model: %s", params.InputFullFileName, params.Model))
+
+	videoMetaData, err := u.GetVideoMetadata(ctx, params.TempFilePath)
+	if err != nil {
+		return withKind(ErrInput, fmt.Errorf("error getting video details: %v", err))
+	}
+
+	if err := u.resolveFrameRate(ctx, params, videoMetaData); err != nil {
+		return withKind(ErrInput, err)
+	}
+	if params.FrameRateMode == constants.FrameRateModeVFR {
+		// Raw frames on a pipe carry no timestamps, the average rate keeps the duration
+		u.logger.Warning(fmt.Sprintf("⚠️ Streaming can't keep variable frame timestamps, encoding at a constant %.3f fps", rationalFloat(params.FrameRate)))
+		params.FrameRateMode = constants.FrameRateModeCFR
+	}
+
+	if params.Plan, err = u.planResolution(params, videoMetaData); err != nil {
+		return err
+	}
+	params.ScaleMultiplier = params.Plan.ModelScale
+	u.logger.Info(fmt.Sprintf("📐 Resolution plan: %s", describePlan(params.Plan)))
+
+	if err := resolveTrim(params, videoMetaData); err != nil {
+		return err
+	}
+
+	if err := u.ExtractAudio(ctx, params); err != nil {
+		return withKind(ErrEncode, fmt.Errorf("error extracting audio: %v", err))
+	}
+
+	progress := u.progressFor(params)
+
+	bufferFrames := params.StreamBufferFrames
+	if bufferFrames <= 0 {
+		bufferFrames = defaultStreamBufferFrames
+	}
+
+	scale := params.Plan.ModelScale
+	width, height := params.Plan.InputWidth, params.Plan.InputHeight
+	streamEngine := u.engineFor(params.Model).(engine.StreamEngine)
+	opts := engine.Options{Model: params.Model, Scale: scale, TileSize: params.TileSize}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Decoder: input video -> rgb24 frames on stdout
+	decodeArgs := []string{"-hide_banner", "-loglevel", "error"}
+	if params.StartFrame > 0 {
+		if position, ok := seekPosition(params.StartFrame, videoMetaData); ok {
+			decodeArgs = append(decodeArgs, "-accurate_seek", "-ss", position)
+		}
+	}
+	decodeArgs = append(decodeArgs, "-i", params.TempFilePath)
+	if scaleFilter := preScaleFilter(&params.Plan); scaleFilter != "" {
+		decodeArgs = append(decodeArgs, "-vf", scaleFilter)
+	}
+	if isTrimmed(params, videoMetaData) {
+		decodeArgs = append(decodeArgs, "-frames:v", fmt.Sprintf("%d", params.EndFrame-params.StartFrame))
+	}
+	decodeArgs = append(decodeArgs, "-fps_mode", "passthrough", "-f", "rawvideo", "-pix_fmt", "rgb24", "-")
+
+	decoder := exec.CommandContext(ctx, config.Paths.FFmpegPath, decodeArgs...)
+	utils.HideWindowsCMD(decoder)
+	decoderOut, err := decoder.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	// Encoder: upscaled rgb24 frames on stdin -> video-only file, audio is muxed by MergeVideos.
+	// Matroska takes every supported encoder, the final container is picked when merging
+	encodedPath := filepath.Join(params.TempDir, fmt.Sprintf("%s_stream.mkv", params.InputPlainFileName))
+	encodeArgs := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgb24",
+		"-s", fmt.Sprintf("%dx%d", width*scale, height*scale),
+		"-framerate", rationalString(params.FrameRate),
+		"-i", "-",
+	}
+	if params.Plan.FinalFilter != "" {
+		encodeArgs = append(encodeArgs, "-vf", params.Plan.FinalFilter)
+	}
+	encodeArgs = append(encodeArgs, encoderArgs(params.Encoder)...)
+	encodeArgs = append(encodeArgs, "-y", encodedPath)
+
+	encoder := exec.CommandContext(ctx, config.Paths.FFmpegPath, encodeArgs...)
+	utils.HideWindowsCMD(encoder)
+	encoderIn, err := encoder.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := decoder.Start(); err != nil {
+		return withKind(ErrInput, fmt.Errorf("failed to start decoder: %v", err))
+	}
+	if err := encoder.Start(); err != nil {
+		cancel()
+		decoder.Wait()
+		return withKind(ErrEncode, fmt.Errorf("failed to start encoder: %v", err))
+	}
+
+	// Bounded channels between the stages keep memory usage predictable
+	decoded := make(chan engine.Frame, bufferFrames)
+	upscaled := make(chan engine.Frame, bufferFrames)
+
+	progress.StartUpscaling(params.EndFrame-params.StartFrame, 1, 0)
+	progress.StartBatch(1)
+	encodedFrames := 0
+
+	stageErrs := make(chan error, 3)
+	go func() {
+		err := engine.ReadRawFrames(ctx, decoderOut, width, height, decoded)
+		if err != nil {
+			cancel()
+		}
+		if waitErr := decoder.Wait(); err == nil {
+			err = waitErr
+		}
+		if err != nil {
+			err = withKind(ErrInput, fmt.Errorf("decoding failed: %v", err))
+		}
+		stageErrs <- err
+	}()
+	go func() {
+		err := streamEngine.UpscaleStream(ctx, decoded, upscaled, width, height, opts)
+		if err != nil {
+			err = withKind(ErrEngine, fmt.Errorf("upscaling failed: %v", err))
+		}
+		stageErrs <- err
+	}()
+	go func() {
+		err := engine.WriteRawFrames(ctx, encoderIn, upscaled, func(engine.Frame) {
+			encodedFrames++
+			progress.AddFrames(1)
+		})
+		encoderIn.Close()
+		if waitErr := encoder.Wait(); err == nil {
+			err = waitErr
+		}
+		if err != nil {
+			err = withKind(ErrEncode, fmt.Errorf("encoding failed: %v", err))
+		}
+		stageErrs <- err
+	}()
+
+	var pipelineErr error
+	for i := 0; i < 3; i++ {
+		if err := <-stageErrs; err != nil && pipelineErr == nil {
+			pipelineErr = err
+			cancel() // stop the other stages
+		}
+	}
+	if pipelineErr != nil {
+		return pipelineErr
+	}
+
+	progress.SetStage(constants.ProgressStageMerging, 90)
+	u.logger.Info("⚙️ Merging video")
+	if err := u.MergeVideos(ctx, []string{encodedPath}, params); err != nil {
+		return withKind(ErrEncode, fmt.Errorf("error merging final video: %v", err))
+	}
+
+	progress.SetStage(constants.ProgressStageCleanup, 95)
+	u.logger.Info("🧹 Cleaning temporary files")
+	os.RemoveAll(params.TempDir)
+
+	progress.SetStage(constants.ProgressStageDone, 100)
+
+	totalElapsed := time.Since(startTime).Seconds()
+	u.logger.Info(fmt.Sprintf("✅ Streaming upscale completed! Took: %dm%.2fs! 📊 Frames: %d | Model: %s | Pre-scale: %.2fx (%s) | Scale: %dx | output: %dx%d", int(totalElapsed/60), math.Mod(totalElapsed, 60), encodedFrames, describePasses(params.Plan.Passes), params.Plan.PreScaleFactor, params.Plan.PreScalePolicy, scale, params.Plan.OutputWidth, params.Plan.OutputHeight))
+
+	return nil
+}
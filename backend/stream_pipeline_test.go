@@ -0,0 +1,32 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/engine"
+)
+
+func TestValidateStreaming(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  datatransfers.VideoUpscalerRequest
+		wantErr bool
+	}{
+		{name: "batch mode", params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus"}},
+		{name: "fake engine", params: datatransfers.VideoUpscalerRequest{Model: engine.FakeModel, Streaming: true}},
+		{name: "ffmpeg scaler", params: datatransfers.VideoUpscalerRequest{Model: engine.FFmpegModelSpline, Streaming: true}},
+		{name: "single pass", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: engine.FakeModel}}, Streaming: true}},
+		{name: "real-esrgan", params: datatransfers.VideoUpscalerRequest{Model: "realesrgan-x4plus", Streaming: true}, wantErr: true},
+		{name: "chained passes", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: engine.FakeModel}, {Model: engine.FakeModel}}, Streaming: true}, wantErr: true},
+	}
+
+	u := newFakeUpscaler(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := u.ValidateStreaming(&tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateStreaming() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Checksums maps the path of an embedded file to its SHA-256, as listed in
+// embeds/checksums.sha256 (sha256sum format, regenerated with `go generate`).
+type Checksums map[string]string
+
+func ParseChecksums(data []byte) (Checksums, error) {
+	checksums := make(Checksums)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		sum, path, ok := strings.Cut(text, " ")
+		if !ok || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid checksum line %d: %q", line, text)
+		}
+		checksums[strings.TrimPrefix(strings.TrimSpace(path), "*")] = strings.ToLower(sum)
+	}
+	return checksums, scanner.Err()
+}
+
+// Version identifies the set of embedded files, it changes whenever one of them does.
+func (c Checksums) Version() string {
+	paths := make([]string, 0, len(c))
+	for path := range c {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(hash, "%s  %s\n", c[path], path)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// staleCacheAge is how long the dir of another version must go unused before it
+// is removed, another installed version may be running from it.
+const staleCacheAge = 30 * 24 * time.Hour
+
+// CacheDir returns the per-user cache dir of the given version of the embedded
+// files, creating it when needed and marking it used. Dirs of other versions
+// are removed once they went unused for staleCacheAge.
+func CacheDir(appName, version string) (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	root := filepath.Join(userCacheDir, appName)
+	cacheDir := filepath.Join(root, version)
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	os.Chtimes(cacheDir, now, now)
+
+	entries, err := os.ReadDir(root)
+	if err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() || entry.Name() == version {
+				continue
+			}
+			if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > staleCacheAge {
+				os.RemoveAll(filepath.Join(root, entry.Name()))
+			}
+		}
+	}
+	return cacheDir, nil
+}
+
+// ExtractVerified writes the embedded file src to dst unless dst already holds
+// the expected content. It reports whether the file had to be (re)written.
+// Files without a listed checksum are compared to the embedded bytes.
+func ExtractVerified(embedded fs.FS, src, dst string, checksums Checksums, perm os.FileMode) (bool, error) {
+	data, err := fs.ReadFile(embedded, src)
+	if err != nil {
+		return false, fmt.Errorf("failed to read embedded file %s: %v", src, err)
+	}
+
+	expected, ok := checksums[src]
+	if !ok {
+		expected = checksumOf(data)
+	} else if actual := checksumOf(data); actual != expected {
+		return false, fmt.Errorf("embedded file %s does not match its checksum, run go generate", src)
+	}
+
+	if actual, err := fileChecksum(dst); err == nil && actual == expected {
+		return false, nil
+	}
+
+	// Write to a temp file of its own next to the target and rename, concurrent
+	// runs never write the same file nor see a partial one
+	tmpFile, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return false, fmt.Errorf("failed to write file %s: %v", dst, err)
+	}
+	tmpPath := tmpFile.Name()
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dst)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return false, fmt.Errorf("failed to write file %s: %v", dst, err)
+	}
+	return true, nil
+}
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestExtractVerifiedConcurrently(t *testing.T) {
+	content := []byte("embedded tool")
+	embedded := fstest.MapFS{"embeds/tool.exe": {Data: content}}
+	checksums := Checksums{"embeds/tool.exe": checksumOf(content)}
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "tool.exe")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ExtractVerified(embedded, "embeds/tool.exe", dst, checksums, 0755); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if data, err := os.ReadFile(dst); err != nil || string(data) != string(content) {
+		t.Errorf("extracted %q, %v, want %q", data, err, content)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("dir holds %d files, want only the extracted one", len(entries))
+	}
+
+	if extracted, err := ExtractVerified(embedded, "embeds/tool.exe", dst, checksums, 0755); err != nil || extracted {
+		t.Errorf("extracted = %v, err = %v, want the intact file reused", extracted, err)
+	}
+}
+
+func TestCacheDirKeepsVersionsInUse(t *testing.T) {
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	t.Setenv("LocalAppData", cacheHome)
+	t.Setenv("HOME", cacheHome)
+
+	root, err := CacheDir("app", "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = filepath.Dir(root)
+	if _, err := CacheDir("app", "running"); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-staleCacheAge - time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "old"), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CacheDir("app", "new"); err != nil {
+		t.Fatal(err)
+	}
+	for version, want := range map[string]bool{"old": false, "running": true, "new": true} {
+		if _, err := os.Stat(filepath.Join(root, version)); (err == nil) != want {
+			t.Errorf("cache of version %s exists = %v, want %v", version, err == nil, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+)
+
+type Tool string
+
+const (
+	FFmpeg     Tool = "ffmpeg"
+	FFprobe    Tool = "ffprobe"
+	RealEsrgan Tool = "realesrgan-ncnn-vulkan"
+)
+
+// Required tools make startup fail when missing. Without Real-ESRGAN the
+// upscaler falls back to the ffmpeg scaler engine.
+var Required = map[Tool]bool{
+	FFmpeg:     true,
+	FFprobe:    true,
+	RealEsrgan: false,
+}
+
+const validateTimeout = 10 * time.Second
+
+// BinaryName returns the file name of a tool on the current OS.
+func BinaryName(tool Tool) string {
+	if runtime.GOOS == "windows" {
+		return string(tool) + ".exe"
+	}
+	return string(tool)
+}
+
+// Overrides returns the user-configured tool paths, configured maps tool names
+// to paths and the environment variables take precedence over it.
+func Overrides(configured map[string]string) map[Tool]string {
+	overrides := make(map[Tool]string)
+	for name, path := range configured {
+		if path != "" {
+			overrides[Tool(name)] = path
+		}
+	}
+	for tool, env := range map[Tool]string{
+		FFmpeg:     constants.EnvFFmpegPath,
+		FFprobe:    constants.EnvFFprobePath,
+		RealEsrgan: constants.EnvRealesrganPath,
+	} {
+		if path := os.Getenv(env); path != "" {
+			overrides[tool] = path
+		}
+	}
+	return overrides
+}
+
+// Resolver finds the binary of each tool, trying in order the user-configured
+// path, the binary embedded for this OS/arch and $PATH. Every candidate is run
+// once to make sure it actually works on this machine.
+type Resolver struct {
+	embedded      fs.FS
+	embeddedPaths map[Tool]string // path inside embedded, absent when not bundled for this platform
+	checksums     Checksums
+	extractDir    string
+	overrides     map[Tool]string
+}
+
+func NewResolver(embedded fs.FS, embeddedPaths map[Tool]string, checksums Checksums, extractDir string, overrides map[Tool]string) *Resolver {
+	return &Resolver{
+		embedded:      embedded,
+		embeddedPaths: embeddedPaths,
+		checksums:     checksums,
+		extractDir:    extractDir,
+		overrides:     overrides,
+	}
+}
+
+// Resolve looks up a single tool. A configured path is never silently replaced,
+// if it does not work the diagnostic reports why.
+func (r *Resolver) Resolve(ctx context.Context, tool Tool) datatransfers.ToolDiagnostic {
+	diagnostic := datatransfers.ToolDiagnostic{Tool: string(tool), Required: Required[tool]}
+
+	if path, ok := r.overrides[tool]; ok {
+		diagnostic.Path = path
+		diagnostic.Source = constants.ToolSourceConfigured
+		version, err := validate(ctx, tool, path)
+		if err != nil {
+			diagnostic.Error = fmt.Sprintf("configured path %s is not usable: %v", path, err)
+		}
+		diagnostic.Version = version
+		return diagnostic
+	}
+
+	var tried []string
+
+	if embeddedPath, ok := r.embeddedPaths[tool]; ok {
+		path, err := r.extract(tool, embeddedPath)
+		if err == nil {
+			var version string
+			if version, err = validate(ctx, tool, path); err == nil {
+				diagnostic.Path = path
+				diagnostic.Source = constants.ToolSourceEmbedded
+				diagnostic.Version = version
+				return diagnostic
+			}
+		}
+		tried = append(tried, fmt.Sprintf("embedded binary: %v", err))
+	} else {
+		tried = append(tried, fmt.Sprintf("embedded binary: none bundled for %s/%s", runtime.GOOS, runtime.GOARCH))
+	}
+
+	path, err := exec.LookPath(BinaryName(tool))
+	if err == nil {
+		var version string
+		if version, err = validate(ctx, tool, path); err == nil {
+			diagnostic.Path = path
+			diagnostic.Source = constants.ToolSourcePath
+			diagnostic.Version = version
+			return diagnostic
+		}
+	}
+	tried = append(tried, fmt.Sprintf("$PATH: %v", err))
+
+	diagnostic.Error = fmt.Sprintf("%s not found (%s)", tool, strings.Join(tried, "; "))
+	return diagnostic
+}
+
+// ResolveAll resolves every known tool. The error lists the missing required
+// tools, the diagnostics are returned either way.
+func (r *Resolver) ResolveAll(ctx context.Context) ([]datatransfers.ToolDiagnostic, error) {
+	var (
+		diagnostics []datatransfers.ToolDiagnostic
+		missing     []string
+	)
+	for _, tool := range []Tool{FFmpeg, FFprobe, RealEsrgan} {
+		diagnostic := r.Resolve(ctx, tool)
+		diagnostics = append(diagnostics, diagnostic)
+		if diagnostic.Error != "" && diagnostic.Required {
+			missing = append(missing, diagnostic.Error)
+		}
+	}
+
+	if len(missing) > 0 {
+		return diagnostics, fmt.Errorf("required tools are missing, install them or set %s/%s:\n%s",
+			constants.EnvFFmpegPath, constants.EnvFFprobePath, strings.Join(missing, "\n"))
+	}
+	return diagnostics, nil
+}
+
+// Report formats the diagnostics one tool per line for logs and the CLI.
+func Report(diagnostics []datatransfers.ToolDiagnostic) string {
+	var sb strings.Builder
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Error != "" {
+			fmt.Fprintf(&sb, "✖ %s: %s\n", diagnostic.Tool, diagnostic.Error)
+			continue
+		}
+		fmt.Fprintf(&sb, "✔ %s: %s (%s) %s\n", diagnostic.Tool, diagnostic.Path, diagnostic.Source, diagnostic.Version)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// extract copies the embedded binary to the extract dir, an intact copy left by
+// a previous run is reused.
+func (r *Resolver) extract(tool Tool, embeddedPath string) (string, error) {
+	path := filepath.Join(r.extractDir, BinaryName(tool))
+	if _, err := ExtractVerified(r.embedded, embeddedPath, path, r.checksums, 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// validate runs the tool with -version and returns the first line it prints.
+// realesrgan-ncnn-vulkan has no version flag, it prints its usage and exits
+// non-zero, which is enough to know the binary runs on this platform.
+func validate(ctx context.Context, tool Tool, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "-version")
+	utils.HideWindowsCMD(cmd)
+	output, err := cmd.CombinedOutput()
+
+	if tool == RealEsrgan {
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return "", err
+		}
+		if !bytes.Contains(output, []byte("Usage")) {
+			return "", fmt.Errorf("unexpected output: %s", firstLine(output))
+		}
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, firstLine(output))
+	}
+	return firstLine(output), nil
+}
+
+func firstLine(output []byte) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+// parseTimestamp parses seconds ("90.5") or a clock time ("1:30.5", "00:01:30.500").
+func parseTimestamp(value string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	seconds := 0.0
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 || (i > 0 && number >= 60) {
+			return 0, fmt.Errorf("invalid timestamp %q, expected e.g. 90.5 or 00:01:30.5", value)
+		}
+		seconds = seconds*60 + number
+	}
+	return seconds, nil
+}
+
+// ValidateTrim checks the trim range of a request before it is queued, frame
+// bounds are checked against the video once it is probed.
+func ValidateTrim(params *datatransfers.VideoUpscalerRequest) error {
+	start, end := -1.0, -1.0
+	var err error
+	if params.StartTime != "" {
+		if start, err = parseTimestamp(params.StartTime); err != nil {
+			return err
+		}
+	}
+	if params.EndTime != "" {
+		if end, err = parseTimestamp(params.EndTime); err != nil {
+			return err
+		}
+	}
+	if start >= 0 && end >= 0 && end <= start {
+		return fmt.Errorf("end time %s must be after start time %s", params.EndTime, params.StartTime)
+	}
+
+	if params.StartFrame < 0 || params.EndFrame < 0 {
+		return fmt.Errorf("start and end frames can't be negative")
+	}
+	if params.EndFrame > 0 && params.EndFrame <= params.StartFrame {
+		return fmt.Errorf("end frame %d must be after start frame %d", params.EndFrame, params.StartFrame)
+	}
+	return nil
+}
+
+// timestampFrame returns the first frame shown at or after seconds.
+func timestampFrame(seconds float64, params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) int {
+	if timestamps := videoMetadata.FrameTimestamps; len(timestamps) > 0 {
+		return sort.SearchFloat64s(timestamps, seconds-1e-6)
+	}
+	return int(math.Round(seconds * rationalFloat(params.FrameRate)))
+}
+
+// frameSeconds returns the time frame index is shown at.
+func frameSeconds(index int, params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) float64 {
+	return frameTimestamp(videoMetadata.FrameTimestamps, index, rationalFloat(params.FrameRate))
+}
+
+// resolveTrim turns the requested range into the frames [StartFrame, EndFrame)
+// and, when part of the video is left out, the matching TrimStartSeconds and
+// TrimEndSeconds. Timestamps take precedence over frame numbers, an open end runs
+// to the end of the video. Requested times count from the first frame, the
+// resolved seconds from the start of the file like the seeks of the frames.
+func resolveTrim(params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) error {
+	if err := ValidateTrim(params); err != nil {
+		return err
+	}
+
+	totalFrames := videoMetadata.TotalFrames
+	if params.StartTime != "" {
+		start, _ := parseTimestamp(params.StartTime)
+		params.StartFrame = timestampFrame(start, params, videoMetadata)
+	}
+	if params.EndTime != "" {
+		end, _ := parseTimestamp(params.EndTime)
+		params.EndFrame = timestampFrame(end, params, videoMetadata)
+	}
+	if params.EndFrame == 0 || params.EndFrame > totalFrames {
+		params.EndFrame = totalFrames
+	}
+	if params.StartFrame >= params.EndFrame {
+		return fmt.Errorf("trim range starts at frame %d, past the end of the video (%d frames)", params.StartFrame, params.EndFrame)
+	}
+
+	params.TrimStartSeconds, params.TrimEndSeconds = 0, 0
+	if isTrimmed(params, videoMetadata) {
+		params.TrimStartSeconds = videoMetadata.StartOffset + frameSeconds(params.StartFrame, params, videoMetadata)
+		params.TrimEndSeconds = videoMetadata.StartOffset + frameSeconds(params.EndFrame, params, videoMetadata)
+	}
+	return nil
+}
+
+// isTrimmed reports whether a resolved range leaves part of the video out.
+func isTrimmed(params *datatransfers.VideoUpscalerRequest, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) bool {
+	return params.StartFrame > 0 || params.EndFrame < videoMetadata.TotalFrames
+}
+
+// trimInputArgs returns the input options cutting audio, subtitles and chapters
+// of the source to the resolved range, empty when the whole video is used.
+func trimInputArgs(params *datatransfers.VideoUpscalerRequest) []string {
+	if params.TrimEndSeconds <= 0 {
+		return nil
+	}
+	return []string{
+		"-ss", fmt.Sprintf("%.6f", params.TrimStartSeconds),
+		"-to", fmt.Sprintf("%.6f", params.TrimEndSeconds),
+	}
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "90.5", want: 90.5},
+		{value: " 90 ", want: 90},
+		{value: "1:30.5", want: 90.5},
+		{value: "00:01:30.500", want: 90.5},
+		{value: "2:00:00", want: 7200},
+		{value: "1:60", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "1:2:3:4", wantErr: true},
+		{value: "1m30s", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseTimestamp(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTrimInputArgs(t *testing.T) {
+	params := datatransfers.VideoUpscalerRequest{StartTime: "2", EndTime: "4", FrameRate: datatransfers.Rational{Num: 25, Den: 1}}
+	videoMetadata := datatransfers.FFProbeStreamsMetadataResponse{TotalFrames: 250, FrameRate: params.FrameRate, StartOffset: 0.3}
+	if err := resolveTrim(&params, &videoMetadata); err != nil {
+		t.Fatal(err)
+	}
+
+	// The audio is cut where the frames are seeked, past the offset of the video
+	want := []string{"-ss", "2.300000", "-to", "4.300000"}
+	if got := trimInputArgs(&params); !slices.Equal(got, want) {
+		t.Errorf("trimInputArgs() = %v, want %v", got, want)
+	}
+	if position, _ := seekPosition(params.StartFrame, &videoMetadata); position != "2.280000" {
+		t.Errorf("seekPosition() = %s, want the frame before 2.3s", position)
+	}
+}
+
+func TestResolveTrim(t *testing.T) {
+	constantRate := datatransfers.FFProbeStreamsMetadataResponse{TotalFrames: 250}
+	variableRate := datatransfers.FFProbeStreamsMetadataResponse{TotalFrames: 5, FrameTimestamps: []float64{0, 0.1, 0.3, 0.6, 1.0}}
+	audioFirst := datatransfers.FFProbeStreamsMetadataResponse{TotalFrames: 250, StartOffset: 0.3}
+
+	tests := []struct {
+		name                 string
+		params               datatransfers.VideoUpscalerRequest
+		videoMetadata        datatransfers.FFProbeStreamsMetadataResponse
+		wantStart, wantEnd   int
+		wantStartS, wantEndS float64
+		wantErr              bool
+	}{
+		{name: "whole video", videoMetadata: constantRate, wantEnd: 250},
+		{name: "start time", params: datatransfers.VideoUpscalerRequest{StartTime: "2"}, videoMetadata: constantRate, wantStart: 50, wantEnd: 250, wantStartS: 2, wantEndS: 10},
+		{name: "clock range", params: datatransfers.VideoUpscalerRequest{StartTime: "0:01", EndTime: "00:00:03.5"}, videoMetadata: constantRate, wantStart: 25, wantEnd: 88, wantStartS: 1, wantEndS: 3.52},
+		{name: "end frame", params: datatransfers.VideoUpscalerRequest{EndFrame: 100}, videoMetadata: constantRate, wantEnd: 100, wantEndS: 4},
+		{name: "time over frame", params: datatransfers.VideoUpscalerRequest{StartTime: "1", StartFrame: 100}, videoMetadata: constantRate, wantStart: 25, wantEnd: 250, wantStartS: 1, wantEndS: 10},
+		{name: "end past the video", params: datatransfers.VideoUpscalerRequest{EndFrame: 400}, videoMetadata: constantRate, wantEnd: 250},
+		{name: "video starts after audio", params: datatransfers.VideoUpscalerRequest{StartTime: "2", EndTime: "4"}, videoMetadata: audioFirst, wantStart: 50, wantEnd: 100, wantStartS: 2.3, wantEndS: 4.3},
+		{name: "frame timestamps", params: datatransfers.VideoUpscalerRequest{StartTime: "0.3"}, videoMetadata: variableRate, wantStart: 2, wantEnd: 5, wantStartS: 0.3, wantEndS: 1.2},
+		{name: "start past the end", params: datatransfers.VideoUpscalerRequest{StartTime: "20"}, videoMetadata: constantRate, wantErr: true},
+		{name: "end before start", params: datatransfers.VideoUpscalerRequest{StartTime: "5", EndTime: "2"}, videoMetadata: constantRate, wantErr: true},
+		{name: "negative frame", params: datatransfers.VideoUpscalerRequest{StartFrame: -1}, videoMetadata: constantRate, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			params.FrameRate = datatransfers.Rational{Num: 25, Den: 1}
+			if tt.videoMetadata.FrameTimestamps != nil {
+				params.FrameRate = datatransfers.Rational{Num: 5, Den: 1}
+			}
+
+			err := resolveTrim(&params, &tt.videoMetadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTrim() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if params.StartFrame != tt.wantStart || params.EndFrame != tt.wantEnd {
+				t.Errorf("frames = [%d, %d), want [%d, %d)", params.StartFrame, params.EndFrame, tt.wantStart, tt.wantEnd)
+			}
+			if math.Abs(params.TrimStartSeconds-tt.wantStartS) > 1e-9 || math.Abs(params.TrimEndSeconds-tt.wantEndS) > 1e-9 {
+				t.Errorf("seconds = %v - %v, want %v - %v", params.TrimStartSeconds, params.TrimEndSeconds, tt.wantStartS, tt.wantEndS)
+			}
+		})
+	}
+}
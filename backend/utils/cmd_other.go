@@ -0,0 +1,8 @@
+//go:build !windows
+
+package utils
+
+import "os/exec"
+
+// HideWindowsCMD is a no-op outside Windows, there is no console window to hide.
+func HideWindowsCMD(cmd *exec.Cmd) {}
@@ -3,33 +3,86 @@ package utils
 import (
 	"context"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
 )
 
-func HideWindowsCMD(cmd *exec.Cmd) {
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	}
+// executable is swapped by tests to move the folders next to the executable.
+var executable = os.Executable
+
+// GetOutputVideoFolder returns output_videos next to the executable, as the
+// portable build expects, or Videos/<appName> in the user home when the
+// executable sits somewhere read-only such as /usr/bin or Program Files.
+func GetOutputVideoFolder(appName string) (string, error) {
+	return firstWritableFolder(
+		func() (string, error) { return nextToExecutable("output_videos") },
+		func() (string, error) {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(homeDir, "Videos", appName), nil
+		},
+	)
 }
 
-func GetOutputVideoFolder() (string, error) {
-	exePath, err := os.Executable()
+// GetJobsFolder returns the folder holding resumable job data. Unlike the
+// session temp dir it is kept across restarts, next to the executable or in
+// the user cache dir when that is read-only.
+func GetJobsFolder(appName string) (string, error) {
+	return firstWritableFolder(
+		func() (string, error) { return nextToExecutable(constants.JobsFolderName) },
+		func() (string, error) {
+			cacheDir, err := os.UserCacheDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(cacheDir, appName, constants.JobsFolderName), nil
+		},
+	)
+}
+
+func nextToExecutable(name string) (string, error) {
+	exePath, err := executable()
 	if err != nil {
 		return "", err
 	}
-	exeDir := filepath.Dir(exePath)
-	outputFolder := filepath.Join(exeDir, "output_videos")
-	if err := os.MkdirAll(outputFolder, os.ModePerm); err != nil {
-		return "", err
+	return filepath.Join(filepath.Dir(exePath), name), nil
+}
+
+// firstWritableFolder creates and returns the first candidate folder files can
+// be written to, or the error of the last one.
+func firstWritableFolder(candidates ...func() (string, error)) (string, error) {
+	var lastErr error
+	for _, candidate := range candidates {
+		folder, err := candidate()
+		if err == nil {
+			err = checkWritable(folder)
+		}
+		if err == nil {
+			return folder, nil
+		}
+		lastErr = err
 	}
+	return "", lastErr
+}
 
-	return outputFolder, nil
+// checkWritable creates folder and a file in it, an existing folder can still
+// be read-only.
+func checkWritable(folder string) error {
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(folder, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
 }
 
 func NowUnix() int {
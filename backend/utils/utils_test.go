@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+)
+
+func TestFoldersNextToWritableExecutable(t *testing.T) {
+	exeDir := t.TempDir()
+	executable = func() (string, error) { return filepath.Join(exeDir, "app"), nil }
+	t.Cleanup(func() { executable = os.Executable })
+
+	outputFolder, err := GetOutputVideoFolder("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(exeDir, "output_videos"); outputFolder != want {
+		t.Errorf("output folder = %s, want %s", outputFolder, want)
+	}
+
+	jobsFolder, err := GetJobsFolder("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(exeDir, constants.JobsFolderName); jobsFolder != want {
+		t.Errorf("jobs folder = %s, want %s", jobsFolder, want)
+	}
+}
+
+func TestFoldersFallBackToUserDirs(t *testing.T) {
+	// A path under a regular file can't be created, even by root
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	executable = func() (string, error) { return filepath.Join(blocker, "bin", "app"), nil }
+	t.Cleanup(func() { executable = os.Executable })
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("LocalAppData", filepath.Join(home, "AppData", "Local"))
+
+	outputFolder, err := GetOutputVideoFolder("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "Videos", "app"); outputFolder != want {
+		t.Errorf("output folder = %s, want %s", outputFolder, want)
+	}
+
+	jobsFolder, err := GetJobsFolder("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(cacheDir, "app", constants.JobsFolderName); jobsFolder != want {
+		t.Errorf("jobs folder = %s, want %s", jobsFolder, want)
+	}
+	if _, err := os.Stat(jobsFolder); err != nil {
+		t.Errorf("jobs folder not created: %v", err)
+	}
+}
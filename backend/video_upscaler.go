@@ -10,55 +10,164 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
 	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/engine"
 	"github.com/riskibarqy/RevivePixels/backend/models"
 	"github.com/riskibarqy/RevivePixels/backend/utils"
-
-	"github.com/google/uuid"
 )
 
 type VideoUpscalerUsecase interface {
 	ExtractAudio(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error
-	ExtractVideoFrames(ctx context.Context, frameDir, videoPath string, startFrame, frameCount, scaleMultiplier int, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) error
+	ExtractVideoFrames(ctx context.Context, frameDir, videoPath string, startFrame, frameCount int, plan *datatransfers.ResolutionPlan, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) error
 	GetVideoMetadata(ctx context.Context, inputPath string) (*datatransfers.FFProbeStreamsMetadataResponse, error)
 	MergeVideos(ctx context.Context, videoPaths []string, params *datatransfers.VideoUpscalerRequest) error
 	ReassembleVideo(ctx context.Context, frameDir, outputPath string, params *datatransfers.VideoUpscalerRequest) error
 	UpscaleFrames(ctx context.Context, frames []string, frameDir string, params *datatransfers.VideoUpscalerRequest) error
 	UpscaleVideoWithRealESRGAN(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error
 	GetVideoInfo(ctx context.Context, fileData string) (*datatransfers.VideoInfoResponse, error)
+	ResumeJob(ctx context.Context, jobDir string) (string, error)
+	ListResumableJobs(jobsDir string) ([]*datatransfers.JobManifest, error)
+	PruneJobDirs(jobsDir string) error
+	Progress() <-chan datatransfers.ProgressEvent
+	ValidateEncoder(ctx context.Context, options datatransfers.EncoderOptions) error
+	ValidateStreaming(params *datatransfers.VideoUpscalerRequest) error
+	ValidateModels(params *datatransfers.VideoUpscalerRequest) error
+	ValidateScales(params *datatransfers.VideoUpscalerRequest) error
+	PlanResolution(ctx context.Context, params *datatransfers.VideoUpscalerRequest) (datatransfers.ResolutionPlan, error)
+	ListEncoders(ctx context.Context) ([]datatransfers.EncoderInfo, error)
 }
 
 type videoUpscalerUsecase struct {
 	logger      *utils.CustomLogger
 	sessionApps *sync.Map
+	engines     []engine.UpscaleEngine // first entry is the preferred engine
+	progress    chan datatransfers.ProgressEvent
+	trackers    sync.Map // job ID -> *progressTracker
+
+	probeMu  sync.Mutex
+	probed   bool
+	probeErr error
+
+	encodersMu sync.Mutex
+	encoders   map[string]bool // video encoders of the ffmpeg in use
 }
 
+// NewVideoUpscaler creates the usecase backed by the Real-ESRGAN engine.
 func NewVideoUpscaler(logger *utils.CustomLogger, sessionApps *sync.Map) VideoUpscalerUsecase {
+	return NewVideoUpscalerWithEngine(logger, sessionApps, engine.NewRealEsrgan())
+}
 
+// NewVideoUpscalerWithEngine creates the usecase backed by the given upscale engine.
+// The ffmpeg scaler is always registered as CPU fallback.
+func NewVideoUpscalerWithEngine(logger *utils.CustomLogger, sessionApps *sync.Map, upscaleEngine engine.UpscaleEngine) VideoUpscalerUsecase {
 	return &videoUpscalerUsecase{
 		logger:      logger,
 		sessionApps: sessionApps,
+		engines:     []engine.UpscaleEngine{upscaleEngine, engine.NewFFmpegScaler()},
+		progress:    make(chan datatransfers.ProgressEvent, 256),
 	}
 }
 
+// engineFor returns the registered engine handling the model, defaulting to the preferred engine.
+func (u *videoUpscalerUsecase) engineFor(model string) engine.UpscaleEngine {
+	for _, e := range u.engines {
+		if engine.SupportsModel(e, model) {
+			return e
+		}
+	}
+	return u.engines[0]
+}
+
+// ValidateModels checks before a request is queued that a registered engine
+// lists the model of the request, or of every pass. engineFor hands unknown
+// models to the preferred engine, which would only fail once the job runs.
+func (u *videoUpscalerUsecase) ValidateModels(params *datatransfers.VideoUpscalerRequest) error {
+	for i, pass := range requestPasses(params) {
+		if slices.ContainsFunc(u.engines, func(e engine.UpscaleEngine) bool { return engine.SupportsModel(e, pass.Model) }) {
+			continue
+		}
+
+		var models []string
+		for _, e := range u.engines {
+			models = append(models, e.Capabilities().Models...)
+		}
+		err := fmt.Errorf("unknown model %q, expected one of %s", pass.Model, strings.Join(models, ", "))
+		if len(params.Passes) > 0 {
+			return fmt.Errorf("pass %d: %v", i+1, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// prepareEngine probes the engines selected by params.Model, or by every pass of
+// a chained job, and switches to the ffmpeg scaler when the preferred engine is
+// not usable on this machine.
+func (u *videoUpscalerUsecase) prepareEngine(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+	if len(params.Passes) == 0 {
+		model, err := u.prepareModel(ctx, params.Model)
+		params.Model = model
+		return err
+	}
+
+	for i := range params.Passes {
+		model, err := u.prepareModel(ctx, params.Passes[i].Model)
+		if err != nil {
+			return fmt.Errorf("pass %d: %v", i+1, err)
+		}
+		params.Passes[i].Model = model
+	}
+	return nil
+}
+
+// prepareModel probes the engine of a model and returns the model to use, the
+// ffmpeg lanczos scaler when the preferred engine is unavailable.
+func (u *videoUpscalerUsecase) prepareModel(ctx context.Context, model string) (string, error) {
+	selected := u.engineFor(model)
+	if selected != u.engines[0] {
+		return model, selected.Probe(ctx)
+	}
+
+	// The probe result is cached for the session unless the probe was interrupted
+	u.probeMu.Lock()
+	if !u.probed {
+		err := selected.Probe(ctx)
+		if ctx.Err() != nil {
+			u.probeMu.Unlock()
+			return model, ctx.Err()
+		}
+		u.probed, u.probeErr = true, err
+	}
+	probeErr := u.probeErr
+	u.probeMu.Unlock()
+
+	if probeErr == nil {
+		return model, nil
+	}
+
+	u.logger.Warning(fmt.Sprintf("⚠️ %s unavailable (%v), falling back to %s", selected.Capabilities().Name, probeErr, engine.FFmpegModelLanczos))
+	return engine.FFmpegModelLanczos, u.engineFor(engine.FFmpegModelLanczos).Probe(ctx)
+}
+
 // runCommand executes a shell command and hides the Windows CMD window.
 func runCommand(cmd *exec.Cmd) error {
 	utils.HideWindowsCMD(cmd)
 	return cmd.Run()
 }
 
-// GetVideoMetadata returns the number of frames and FPS of a video.
+// GetVideoMetadata returns the number of frames and the exact frame rate of a video.
 func (u *videoUpscalerUsecase) GetVideoMetadata(ctx context.Context, inputPath string) (*datatransfers.FFProbeStreamsMetadataResponse, error) {
 	cmd := exec.CommandContext(ctx, config.Paths.FFprobePath, "-v", "error", "-select_streams", "v:0",
-		"-show_entries", "stream=nb_frames,r_frame_rate,width,height", "-of", "json", inputPath)
+		"-show_entries", "stream=nb_frames,r_frame_rate,avg_frame_rate,duration,start_time,width,height:format=duration,start_time", "-of", "json", inputPath)
 	utils.HideWindowsCMD(cmd)
 
 	output, err := cmd.Output()
@@ -71,52 +180,68 @@ func (u *videoUpscalerUsecase) GetVideoMetadata(ctx context.Context, inputPath s
 		return nil, err
 	}
 
-	nbFrames, _ := strconv.Atoi(probe.Streams[0].NbFrames)
-	frameRateParts := strings.Split(probe.Streams[0].RFrameRate, "/")
+	if len(probe.Streams) == 0 {
+		return nil, fmt.Errorf("no video stream found in %s", inputPath)
+	}
+
+	nbFrames, frameCountMethod, err := u.countFrames(ctx, inputPath, &probe)
+	if err != nil {
+		return nil, err
+	}
 
-	// Convert "30/1" to 30 FPS
-	numerator, _ := strconv.Atoi(frameRateParts[0])
-	denominator, _ := strconv.Atoi(frameRateParts[1])
-	fps := numerator / denominator
+	// Keep "24000/1001" as is, 23.976 must not become 23
+	frameRate := parseRational(probe.Streams[0].RFrameRate)
+	avgFrameRate := parseRational(probe.Streams[0].AvgFrameRate)
+	isVFR := isVariableFrameRate(frameRate, avgFrameRate)
 
-	u.logger.Info(fmt.Sprintf("ℹ️ Video details : has %d frames at %d FPS", nbFrames, fps))
+	if isVFR {
+		u.logger.Info(fmt.Sprintf("ℹ️ Video details : has %d frames (%s) at a variable frame rate, %.3f FPS on average", nbFrames, frameCountMethod, rationalFloat(avgFrameRate)))
+	} else {
+		u.logger.Info(fmt.Sprintf("ℹ️ Video details : has %d frames (%s) at %.3f FPS", nbFrames, frameCountMethod, rationalFloat(frameRate)))
+	}
 
 	return &datatransfers.FFProbeStreamsMetadataResponse{
-		TotalFrames: nbFrames,
-		FPS:         fps,
-		Width:       probe.Streams[0].Width,
-		Height:      probe.Streams[0].Height,
+		TotalFrames:      nbFrames,
+		FrameCountMethod: frameCountMethod,
+		FrameRate:        frameRate,
+		AvgFrameRate:     avgFrameRate,
+		IsVFR:            isVFR,
+		StartOffset:      startOffset(probe.Streams[0].StartTime, probe.Format.StartTime),
+		Width:            probe.Streams[0].Width,
+		Height:           probe.Streams[0].Height,
 	}, nil
 }
 
-// ExtractVideoFrames extracts a batch of frames from the video to reduce memory usage
-func (u *videoUpscalerUsecase) ExtractVideoFrames(ctx context.Context, frameDir, videoPath string, startFrame, frameCount, scaleMultiplier int, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) error {
-	var actualScaleMultiplier int
-
-	if videoMetadata.Width <= 360 || videoMetadata.Height <= 360 {
-		actualScaleMultiplier = 1
-	} else {
-		actualScaleMultiplier = 2
+// ExtractVideoFrames extracts a batch of frames from the video to reduce memory usage.
+// The input is seeked to startFrame so each batch only decodes from the nearest keyframe
+// instead of from the beginning of the video. A frameCount of 0 extracts up to the end.
+func (u *videoUpscalerUsecase) ExtractVideoFrames(ctx context.Context, frameDir, videoPath string, startFrame, frameCount int, plan *datatransfers.ResolutionPlan, videoMetadata *datatransfers.FFProbeStreamsMetadataResponse) error {
+	var filters []string
+	cmdArgs := []string{}
+	if startFrame > 0 {
+		if position, ok := seekPosition(startFrame, videoMetadata); ok {
+			cmdArgs = append(cmdArgs, "-accurate_seek", "-ss", position)
+		} else {
+			// Unknown frame rate, fall back to selecting by frame number from the start
+			filters = append(filters, fmt.Sprintf("select=gte(n\\,%d)", startFrame))
+		}
 	}
-
-	var scaleFilter string
-	if actualScaleMultiplier > 1 {
-		scaleFilter = fmt.Sprintf("scale='if(gt(iw,360),iw/%d,iw)':'if(gt(ih,360),ih/%d,ih)':force_original_aspect_ratio=decrease", actualScaleMultiplier, actualScaleMultiplier)
+	if scaleFilter := preScaleFilter(plan); scaleFilter != "" {
+		filters = append(filters, scaleFilter)
 	}
 
 	outputPattern := filepath.Join(frameDir, "frame_%04d.png")
-	cmdArgs := []string{
-		"-i", videoPath,
-		"-vf", fmt.Sprintf("select=between(n\\,%d\\,%d)%s", startFrame, startFrame+frameCount-1,
-			func() string {
-				if scaleFilter != "" {
-					return "," + scaleFilter
-				}
-				return ""
-			}()),
+	cmdArgs = append(cmdArgs, "-i", videoPath)
+	if len(filters) > 0 {
+		cmdArgs = append(cmdArgs, "-vf", strings.Join(filters, ","))
+	}
+	if frameCount > 0 {
+		cmdArgs = append(cmdArgs, "-frames:v", fmt.Sprintf("%d", frameCount))
+	}
+	cmdArgs = append(cmdArgs,
 		"-fps_mode", "vfr",
 		outputPattern,
-	}
+	)
 
 	cmd := exec.CommandContext(ctx, config.Paths.FFmpegPath, cmdArgs...)
 
@@ -128,56 +253,106 @@ func (u *videoUpscalerUsecase) ExtractVideoFrames(ctx context.Context, frameDir,
 	return nil
 }
 
-// ExtractAudio extracts the audio track from a video if available.
+// ExtractAudio extracts every audio track of a video, if any, as they are. A single
+// track is stored in the raw format of its codec, several in Matroska audio.
 func (u *videoUpscalerUsecase) ExtractAudio(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
-	hasAudio, err := u.hasAudioStream(ctx, params.TempFilePath)
+	streams, err := u.probeStreams(ctx, params.TempFilePath)
 	if err != nil {
 		return err
 	}
 
-	params.IsHaveAudio = hasAudio
+	audioTracks := countStreams(streams, streamTypeAudio)
+	params.IsHaveAudio = audioTracks > 0
 
 	if !params.IsHaveAudio {
 		return nil
 	}
+	params.AudioFileName = audioFileName(params.InputPlainFileName, streams)
+	u.logger.Info(fmt.Sprintf("🔊 Extracting %d audio track(s) to %s", audioTracks, params.AudioFileName))
 
-	cmd := exec.CommandContext(ctx, config.Paths.FFmpegPath, "-i", params.TempFilePath, "-vn", "-acodec", "copy", params.TempDir+"/"+params.AudioFileName)
+	// Only the trimmed range is kept so the audio starts with the first upscaled frame
+	cmdArgs := append(trimInputArgs(params), "-i", params.TempFilePath, "-map", "0:a", "-c", "copy", "-y", filepath.Join(params.TempDir, params.AudioFileName))
+	cmd := exec.CommandContext(ctx, config.Paths.FFmpegPath, cmdArgs...)
 	return runCommand(cmd)
 }
 
-// hasAudioStream checks if a video contains an audio stream
-func (u *videoUpscalerUsecase) hasAudioStream(ctx context.Context, videoPath string) (bool, error) {
-	cmd := exec.CommandContext(ctx, config.Paths.FFprobePath, "-i", videoPath, "-show_streams", "-select_streams", "a", "-loglevel", "error")
-	utils.HideWindowsCMD(cmd)
-	output, err := cmd.Output()
-	if err != nil {
-		return false, err
-	}
-	return len(output) > 0, nil
+// upscaledFrameDir returns the directory receiving the upscaled frames of a batch.
+// It is a sibling of frameDir so directory-mode engines only see source frames.
+func upscaledFrameDir(frameDir string) string {
+	return frameDir + "_upscaled"
 }
 
-// UpscaleFrames processes multiple frames in parallel using Real-ESRGAN.
+// UpscaleFrames upscales a batch of frames using the configured upscale engine.
+// Engines supporting directory mode process the whole batch in a single call,
+// the others are run once per frame in parallel. The passes of a chained plan
+// run one after the other on png frames, never on an encoded video.
 func (u *videoUpscalerUsecase) UpscaleFrames(ctx context.Context, frames []string, frameDir string, params *datatransfers.VideoUpscalerRequest) error {
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, runtime.NumCPU()/2) // Max concurrent processes
-	errChan := make(chan error, len(frames))             // Collect errors
+	progress := u.progressFor(params)
+
+	passes := params.Plan.Passes
+	if len(passes) == 0 {
+		passes = []datatransfers.UpscalePass{{Model: params.Model, Scale: params.ScaleMultiplier}}
+	}
+
+	// A frame counts once every pass is done, earlier passes advance it proportionally
+	var progressMu sync.Mutex
+	passFrames, reportedFrames := 0, 0
+	onFrames := func(n int) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		passFrames += n
+		if done := passFrames / len(passes); done > reportedFrames {
+			progress.AddFrames(done - reportedFrames)
+			reportedFrames = done
+		}
+	}
 
-	var processedFrames int32 = 0 // Track number of completed frames
-	totalFrames := len(frames)
+	inputDir := frameDir
+	for i, pass := range passes {
+		outputDir := upscaledFrameDir(frameDir)
+		if i < len(passes)-1 {
+			outputDir = fmt.Sprintf("%s_pass%d", frameDir, i+1)
+			os.RemoveAll(outputDir)
+		}
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create upscaled frame directory: %v", err)
+		}
 
-	// Calculate progress range per batch
-	progressStart := 15
-	progressEnd := 85
-	progressRange := progressEnd - progressStart
+		opts := engine.Options{
+			Model:    pass.Model,
+			Scale:    pass.Scale,
+			TileSize: params.TileSize,
+		}
+		if err := u.upscalePass(ctx, frames, inputDir, outputDir, opts, params.Concurrency, onFrames); err != nil {
+			if len(passes) > 1 {
+				return fmt.Errorf("pass %d (%s): %v", i+1, pass.Model, err)
+			}
+			return err
+		}
 
-	// Per-batch progress increase
-	batchProgress := float64(progressRange) / float64(params.TotalBatches)
+		// The frames of the previous pass are not needed anymore
+		if inputDir != frameDir {
+			os.RemoveAll(inputDir)
+		}
+		inputDir = outputDir
+	}
+	return nil
+}
 
-	// Progress range for the current batch
-	batchStart := progressStart + int(batchProgress*float64(params.CurrentBatch-1))
-	batchEnd := batchStart + int(batchProgress)
+// upscalePass runs a single model over the frames of inputDir, writing them with
+// the same names to outputDir.
+func (u *videoUpscalerUsecase) upscalePass(ctx context.Context, frames []string, inputDir, outputDir string, opts engine.Options, concurrency int, onFrames func(n int)) error {
+	upscaleEngine := u.engineFor(opts.Model)
+	if upscaleEngine.Capabilities().SupportsDirectory {
+		return u.upscaleFrameDir(ctx, upscaleEngine, inputDir, outputDir, len(frames), opts, onFrames)
+	}
 
-	progressStep := int32(math.Max(1, float64(totalFrames)/20)) // Log progress every ~5%
+	var wg sync.WaitGroup
+	if concurrency <= 0 {
+		concurrency = max(1, runtime.NumCPU()/2)
+	}
+	semaphore := make(chan struct{}, concurrency) // Max concurrent processes
+	errChan := make(chan error, len(frames))      // Collect errors
 
 	for _, frame := range frames {
 		wg.Add(1)
@@ -186,30 +361,13 @@ func (u *videoUpscalerUsecase) UpscaleFrames(ctx context.Context, frames []strin
 			semaphore <- struct{}{}        // Acquire slot
 			defer func() { <-semaphore }() // Release slot
 
-			outputFrame := filepath.Join(frameDir, "upscaled_"+filepath.Base(frame))
-			cmd := exec.CommandContext(ctx, config.Paths.RealEsrganPath,
-				"-i", frame,
-				"-o", outputFrame,
-				"-s", fmt.Sprintf("%d", params.ScaleMultiplier),
-				"-t", "0", /* tile size (>=32/0=auto, default=0) can be 0,0,0 for multi-gpu */
-				"-n", params.Model,
-				"-g", "0", /* gpu device to use (default=auto) can be 0,1,2 for multi-gpu */
-				"-j", "4:4:4", /* thread count for load/proc/save (default=1:2:2) can be 1:2,2,2:2 for multi-gpu */
-				" --fp16",
-			)
-
-			if err := runCommand(cmd); err != nil {
-				errChan <- fmt.Errorf("failed to upscale frame %s: %w", frame, err)
+			inputFrame := filepath.Join(inputDir, filepath.Base(frame))
+			outputFrame := filepath.Join(outputDir, filepath.Base(frame))
+			if err := upscaleEngine.UpscaleFrame(ctx, inputFrame, outputFrame, opts); err != nil {
+				errChan <- fmt.Errorf("failed to upscale frame %s: %w", inputFrame, err)
 			}
 
-			// Update Progress (Per-Batch Scaling)
-			completed := atomic.AddInt32(&processedFrames, 1)
-			if completed%progressStep == 0 || completed == int32(totalFrames) {
-				progress := batchStart + int((float64(completed)/float64(totalFrames))*(float64(batchEnd-batchStart)))
-				params.LoadingProgress = progress
-
-				u.logger.Trace(fmt.Sprintf("Loading-%d - %s", params.LoadingProgress, params.InputFullFileName))
-			}
+			onFrames(1)
 		}(frame)
 	}
 
@@ -231,31 +389,84 @@ func (u *videoUpscalerUsecase) UpscaleFrames(ctx context.Context, frames []strin
 	return nil
 }
 
+// upscaleFrameDir runs a directory-mode engine over the whole batch and derives
+// per-frame progress from the files appearing in outputDir.
+func (u *videoUpscalerUsecase) upscaleFrameDir(ctx context.Context, upscaleEngine engine.UpscaleEngine, inputDir, outputDir string, totalFrames int, opts engine.Options, onFrames func(n int)) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- upscaleEngine.UpscaleDir(ctx, inputDir, outputDir, opts)
+	}()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastCount := 0
+	advance := func(count int) {
+		if count > lastCount {
+			onFrames(count - lastCount)
+			lastCount = count
+		}
+	}
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("failed to upscale frames in %s: %w", inputDir, err)
+			}
+
+			upscaled, _ := filepath.Glob(filepath.Join(outputDir, "*.png"))
+			if len(upscaled) != totalFrames {
+				return fmt.Errorf("expected %d upscaled frames in %s, found %d", totalFrames, outputDir, len(upscaled))
+			}
+			advance(totalFrames)
+			return nil
+		case <-ticker.C:
+			// The last file may still be written, only count it once the engine returns
+			upscaled, _ := filepath.Glob(filepath.Join(outputDir, "*.png"))
+			advance(min(len(upscaled), totalFrames-1))
+		}
+	}
+}
+
 // ReassembleVideo reassembles frames into a video and adds audio if available.
 func (u *videoUpscalerUsecase) ReassembleVideo(ctx context.Context, frameDir, outputPath string, params *datatransfers.VideoUpscalerRequest) error {
 	u.logger.Info("Reassembling video per frame")
 
-	framePattern := filepath.Join(frameDir, "upscaled_frame_%04d.png")
-	files, err := filepath.Glob(filepath.Join(frameDir, "*.png"))
+	upscaledDir := upscaledFrameDir(frameDir)
+	framePattern := filepath.Join(upscaledDir, "frame_%04d.png")
+	files, err := filepath.Glob(filepath.Join(upscaledDir, "*.png"))
 	if err != nil || len(files) == 0 {
-		return fmt.Errorf("no upscaled frames found in %s", frameDir)
+		return fmt.Errorf("no upscaled frames found in %s", upscaledDir)
 	}
 
-	cmd := exec.CommandContext(ctx, config.Paths.FFmpegPath,
-		"-framerate", fmt.Sprintf("%d", params.VideoFPS),
+	args := []string{
+		"-framerate", rationalString(params.FrameRate),
 		"-i", framePattern,
-		"-c:v", "libx264",
-		"-crf", "18",
-		"-pix_fmt", "yuv420p",
-		outputPath,
-	)
+	}
+	if params.FrameRateMode == constants.FrameRateModeVFR {
+		// Every frame keeps its source duration, the last entry of the list is repeated
+		args = []string{
+			"-f", "concat", "-safe", "0",
+			"-i", filepath.Join(upscaledDir, frameListFileName),
+			"-fps_mode", "vfr", "-frames:v", fmt.Sprintf("%d", len(files)),
+		}
+	}
+	if params.Plan.FinalFilter != "" {
+		args = append(args, "-vf", params.Plan.FinalFilter)
+	}
+	args = append(args, encoderArgs(params.Encoder)...)
+	args = append(args, "-y", outputPath)
+
+	cmd := exec.CommandContext(ctx, config.Paths.FFmpegPath, args...)
 
 	return runCommand(cmd)
 }
 
-// MergeVideos merging reassemble video to one and add adds audio if available.
+// MergeVideos merging reassemble video to one and adds the audio tracks, subtitles,
+// attachments, chapters and metadata of the source the container can hold.
 func (u *videoUpscalerUsecase) MergeVideos(ctx context.Context, videoPaths []string, params *datatransfers.VideoUpscalerRequest) error {
-	listFile := filepath.Join(filepath.Dir(params.TempDir), "video_list.txt")
+	listFile := filepath.Join(params.TempDir, "video_list.txt")
 	file, err := os.Create(listFile)
 	if err != nil {
 		return fmt.Errorf("failed to create list file: %v", err)
@@ -270,18 +481,27 @@ func (u *videoUpscalerUsecase) MergeVideos(ctx context.Context, videoPaths []str
 	// Build FFmpeg command
 	cmdArgs := []string{"-f", "concat", "-safe", "0", "-i", listFile}
 
+	cmdArgs = append(cmdArgs, "-map", "0:v")
+
 	// If audio exists, add audio file (ensure it's properly formatted)
+	sourceInput := 1
 	if params.IsHaveAudio {
 		audioPath := filepath.Join(params.TempDir, params.AudioFileName)
-		cmdArgs = append(cmdArgs, "-i", audioPath)
+		cmdArgs = append(cmdArgs, "-i", audioPath, "-map", "1:a")
+		sourceInput = 2
 	}
 
-	// Set codecs AFTER all inputs
-	cmdArgs = append(cmdArgs, "-c:v", "copy")
-
-	if params.IsHaveAudio {
-		cmdArgs = append(cmdArgs, "-c:a", "aac") // Ensure correct AAC encoding
+	// The source provides everything else that is not video or audio
+	streams, err := u.probeStreams(ctx, params.TempFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to probe source streams: %v", err)
 	}
+	cmdArgs = append(cmdArgs, trimInputArgs(params)...)
+	cmdArgs = append(cmdArgs, "-i", params.TempFilePath)
+
+	// Set codecs AFTER all inputs, audio is encoded to what the container accepts
+	cmdArgs = append(cmdArgs, "-c:v", "copy")
+	cmdArgs = append(cmdArgs, u.muxArgs(params, streams, sourceInput)...)
 
 	// Output file path
 	cmdArgs = append(cmdArgs, "-y", params.SavePath) // "-y" forces overwrite
@@ -293,135 +513,223 @@ func (u *videoUpscalerUsecase) MergeVideos(ctx context.Context, videoPaths []str
 
 // UpscaleVideoWithRealESRGAN Upscaling function using Real-ESRGAN with batch processing
 func (u *videoUpscalerUsecase) UpscaleVideoWithRealESRGAN(ctx context.Context, params *datatransfers.VideoUpscalerRequest) error {
+	return u.upscaleVideo(ctx, params, "")
+}
+
+// upscaleVideo runs the upscale, inputHash is the sha256 of the input when the
+// caller already computed it and empty otherwise.
+func (u *videoUpscalerUsecase) upscaleVideo(ctx context.Context, params *datatransfers.VideoUpscalerRequest, inputHash string) error {
 	startTime := time.Now() // Track overall process start time
-	params.LoadingProgress = 0
 
-	u.logger.Info(fmt.Sprintf("🚀 Starting upscale: %s with model: %s", params.InputFullFileName, params.Model))
+	progress := u.startProgress(params)
+	defer u.stopProgress(params)
+
+	if len(params.Passes) == 1 {
+		// A single pass is a plain single-model job
+		params.Model, params.ScaleMultiplier, params.Passes = params.Passes[0].Model, params.Passes[0].Scale, nil
+	}
+	if len(params.Passes) > 1 {
+		u.logger.Info(fmt.Sprintf("🚀 Starting upscale: %s with passes: %s", params.InputFullFileName, describePasses(params.Passes)))
+	} else {
+		u.logger.Info(fmt.Sprintf("🚀 Starting upscale: %s with model: %s", params.InputFullFileName, params.Model))
+	}
 
 	// Check if input file exists
 	if _, err := os.Stat(params.TempFilePath); os.IsNotExist(err) {
-		return fmt.Errorf("file not found: %s", params.TempFilePath)
+		return withKind(ErrInput, fmt.Errorf("file not found: %s", params.TempFilePath))
+	}
+
+	if err := u.ValidateEncoder(ctx, params.Encoder); err != nil {
+		return fmt.Errorf("invalid encoder settings: %v", err)
+	}
+	if err := ResolveContainer(params); err != nil {
+		return err
+	}
+
+	if err := u.prepareEngine(ctx, params); err != nil {
+		return withKind(ErrEngine, fmt.Errorf("no usable upscale engine: %v", err))
+	}
+
+	if params.Streaming {
+		if err := u.ValidateStreaming(params); err != nil {
+			return withKind(ErrEngine, err)
+		}
+		return u.upscaleVideoStreaming(ctx, params)
 	}
 
 	// Create a temporary directory for storing batch videos
-	tempVideoDir := filepath.Join(filepath.Dir(params.TempFilePath), "temp_videos")
+	tempVideoDir := filepath.Join(params.TempDir, "temp_videos")
 	if err := os.MkdirAll(tempVideoDir, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create temp video directory: %v", err)
 	}
 
-	params.LoadingProgress += 5
-	u.logger.Trace(fmt.Sprintf("Loading-%d - %s", params.LoadingProgress, params.InputFullFileName)) // ✅ 5% - Initial setup done
+	progress.SetStage(constants.ProgressStageProbing, 5) // ✅ 5% - Initial setup done
 
 	// Get total frames and FPS
 	videoMetaData, err := u.GetVideoMetadata(ctx, params.TempFilePath)
 	if err != nil {
-		return fmt.Errorf("error getting video details: %v", err)
+		return withKind(ErrInput, fmt.Errorf("error getting video details: %v", err))
+	}
+
+	progress.SetStage(constants.ProgressStageAudio, 10) // ✅ 10% - Retrieved video details
+
+	// Ensure the frame rate is set
+	if err := u.resolveFrameRate(ctx, params, videoMetaData); err != nil {
+		return withKind(ErrInput, err)
 	}
 
-	params.LoadingProgress += 5
-	u.logger.Trace(fmt.Sprintf("Loading-%d - %s", params.LoadingProgress, params.InputFullFileName)) // ✅ 10% - Retrieved video details
+	// The plan fixes the model scale, it is reported before any frame is touched
+	if params.Plan, err = u.planResolution(params, videoMetaData); err != nil {
+		return err
+	}
+	params.ScaleMultiplier = params.Plan.ModelScale
+	u.logger.Info(fmt.Sprintf("📐 Resolution plan: %s", describePlan(params.Plan)))
 
-	// Ensure FPS is set
-	if params.VideoFPS == 0 {
-		params.VideoFPS = videoMetaData.FPS
+	// Frames outside the trim range are never extracted
+	if err := resolveTrim(params, videoMetaData); err != nil {
+		return err
+	}
+	if isTrimmed(params, videoMetaData) {
+		u.logger.Info(fmt.Sprintf("✂️ Upscaling frames %d - %d (%.3fs - %.3fs)", params.StartFrame+1, params.EndFrame, params.TrimStartSeconds, params.TrimEndSeconds))
 	}
 
-	params.AudioFileName = fmt.Sprintf("%s.aac", params.InputPlainFileName) // Extract audio if available
 	u.logger.Info("Extract audio from the video")
 	if err := u.ExtractAudio(ctx, params); err != nil {
-		return fmt.Errorf("error extracting audio: %v", err)
+		return withKind(ErrEncode, fmt.Errorf("error extracting audio: %v", err))
 	}
 
-	params.LoadingProgress += 5
-	u.logger.Trace(fmt.Sprintf("Loading-%d - %s", params.LoadingProgress, params.InputFullFileName)) // ✅ 15% - Extracted audio
-
-	// Process in batches
-	batchSize := 150
+	// Process in batches, cut on scene changes so the joins land on cuts
 	tempVideos := []string{}
-	totalFrames := videoMetaData.TotalFrames
-	totalBatches := (totalFrames + batchSize - 1) / batchSize
+	rangeStart, rangeEnd := params.StartFrame, params.EndFrame
+	totalFrames := rangeEnd - rangeStart
+
+	// The manifest records the planned and finished batches so an interrupted job can be resumed
+	manifest, err := u.openJobManifest(ctx, params, videoMetaData, inputHash)
+	if err != nil {
+		return fmt.Errorf("error opening job manifest: %v", err)
+	}
+	totalBatches := len(manifest.Batches)
 	params.TotalBatches = totalBatches
+	processedThisRun := 0
+
+	framesDone := 0
+	for _, batch := range manifest.CompletedBatches {
+		framesDone += batch.EndFrame - batch.StartFrame + 1
+	}
+	progress.StartUpscaling(totalFrames, totalBatches, framesDone) // ✅ 15% - Extracted audio
 
-	for i := 0; i < totalFrames; i += batchSize {
+	for batchIndex, batch := range manifest.Batches {
 		batchStartTime := time.Now() // Track time per batch
 
-		uuid := uuid.New().String()
-		endFrame := i + batchSize - 1
-		if endFrame >= totalFrames {
-			endFrame = totalFrames - 1
+		i, endFrame := batch.StartFrame, batch.EndFrame
+
+		if videoPath, ok := isBatchCompleted(manifest, batchIndex); ok {
+			u.logger.Info(fmt.Sprintf("⏭️ Skipping frames %d - %d, already upscaled", i+1, endFrame+1))
+			tempVideos = append(tempVideos, videoPath)
+			continue
 		}
 
-		batchFrameDir := filepath.Join(filepath.Dir(params.TempFilePath), fmt.Sprintf("batch_%s", uuid))
+		// Batch names are deterministic so leftovers of an interrupted run are replaced
+		batchFrameDir := filepath.Join(params.TempDir, fmt.Sprintf("batch_%05d", batchIndex))
+		os.RemoveAll(batchFrameDir)
+		os.RemoveAll(upscaledFrameDir(batchFrameDir))
 		if err := os.MkdirAll(batchFrameDir, os.ModePerm); err != nil {
 			return fmt.Errorf("failed to create batch directory: %v", err)
 		}
 
 		u.logger.Info(fmt.Sprintf("🔄 Processing frames %d - %d", i+1, endFrame+1))
 
-		// Extract frames
-		if err := u.ExtractVideoFrames(ctx, batchFrameDir, params.TempFilePath, i, endFrame-i+1, params.ScaleMultiplier, videoMetaData); err != nil {
-			return fmt.Errorf("error extracting batch: %v", err)
+		// Extract frames, an estimated count may be short so the last batch runs to the end
+		frameCount := endFrame - i + 1
+		if videoMetaData.FrameCountMethod == constants.FrameCountEstimate && endFrame == videoMetaData.TotalFrames-1 {
+			frameCount = 0
+		}
+		if err := u.ExtractVideoFrames(ctx, batchFrameDir, params.TempFilePath, i, frameCount, &params.Plan, videoMetaData); err != nil {
+			return withKind(ErrInput, fmt.Errorf("error extracting batch: %v", err))
 		}
 
 		// Get list of extracted frames
 		frames, err := filepath.Glob(filepath.Join(batchFrameDir, "*.png"))
 		sort.Strings(frames)
+		if err == nil && len(frames) == 0 && i > rangeStart && videoMetaData.FrameCountMethod == constants.FrameCountEstimate {
+			// The estimate was a frame too high and the previous batch ended the video
+			u.logger.Info(fmt.Sprintf("ℹ️ Video ended at frame %d, before the estimated %d", i, videoMetaData.TotalFrames))
+			os.RemoveAll(batchFrameDir)
+			break
+		}
 		if err != nil || len(frames) == 0 {
-			return fmt.Errorf("no frames found in %s", batchFrameDir)
+			return withKind(ErrInput, fmt.Errorf("no frames found in %s", batchFrameDir))
 		}
 
-		params.CurrentBatch = (i / batchSize) + 1
+		params.CurrentBatch = batchIndex + 1
+		progress.StartBatch(params.CurrentBatch)
 
 		// Upscale frames
 		if err := u.UpscaleFrames(ctx, frames, batchFrameDir, params); err != nil {
-			return fmt.Errorf("error upscaling batch: %v", err)
+			return withKind(ErrEngine, fmt.Errorf("error upscaling batch: %v", err))
+		}
+
+		if params.FrameRateMode == constants.FrameRateModeVFR {
+			if err := writeFrameList(upscaledFrameDir(batchFrameDir), i, params, videoMetaData); err != nil {
+				return fmt.Errorf("error timing batch frames: %v", err)
+			}
 		}
 
 		// Create batch video
-		batchVideoPath := filepath.Join(tempVideoDir, fmt.Sprintf("temp_batch_%s.mp4", uuid))
+		// Matroska takes every supported encoder, the final container is picked when merging
+		batchVideoPath := filepath.Join(tempVideoDir, fmt.Sprintf("temp_batch_%05d.mkv", batchIndex))
 
 		if err := u.ReassembleVideo(ctx, batchFrameDir, batchVideoPath, params); err != nil {
-			return fmt.Errorf("error reassembling batch video: %v", err)
+			return withKind(ErrEncode, fmt.Errorf("error reassembling batch video: %v", err))
 		}
 
 		tempVideos = append(tempVideos, batchVideoPath) // Store batch video path
 
+		manifest.CompletedBatches = append(manifest.CompletedBatches, datatransfers.CompletedBatch{
+			Index:      batchIndex,
+			StartFrame: i,
+			EndFrame:   endFrame,
+			VideoPath:  batchVideoPath,
+		})
+		if err := saveJobManifest(manifest); err != nil {
+			u.logger.Warning(fmt.Sprintf("⚠️ Failed to checkpoint batch %d: %v", batchIndex+1, err))
+		}
+
 		// Cleanup batch frames
 		os.RemoveAll(batchFrameDir)
+		os.RemoveAll(upscaledFrameDir(batchFrameDir))
 
-		// Dynamic ETA Calculation
-		processedFrames := endFrame + 1
+		// Dynamic ETA Calculation, only frames upscaled in this run count towards the average
+		processedFrames := endFrame + 1 - rangeStart
+		processedThisRun += endFrame - i + 1
 		elapsedTime := time.Since(startTime).Seconds()
 		remainingFrames := totalFrames - processedFrames
 
-		avgTimePerFrame := elapsedTime / float64(processedFrames)
+		avgTimePerFrame := elapsedTime / float64(processedThisRun)
 		estimatedRemainingTime := time.Duration(avgTimePerFrame * float64(remainingFrames) * float64(time.Second))
 
 		batchElapsed := time.Since(batchStartTime).Seconds()
-		u.logger.Info(fmt.Sprintf("🔄 Batch %d/%d completed in %.2fs. Estimated time remaining: %s", (i/batchSize)+1, (totalFrames/batchSize)+1, batchElapsed, estimatedRemainingTime.Round(time.Second)))
+		u.logger.Info(fmt.Sprintf("🔄 Batch %d/%d completed in %.2fs. Estimated time remaining: %s", batchIndex+1, totalBatches, batchElapsed, estimatedRemainingTime.Round(time.Second)))
 	}
 
-	params.LoadingProgress += 5
-	u.logger.Trace(fmt.Sprintf("Loading-%d - %s", params.LoadingProgress, params.InputFullFileName)) // ✅ 85% - Finished processing all batches
+	progress.SetStage(constants.ProgressStageMerging, 90) // ✅ 90% - Finished processing all batches
 
 	u.logger.Info("⚙️ Merging video")
 	// Merge all batch videos into the final video
 	if err := u.MergeVideos(ctx, tempVideos, params); err != nil {
-		return fmt.Errorf("error merging final video: %v", err)
+		return withKind(ErrEncode, fmt.Errorf("error merging final video: %v", err))
 	}
 
-	params.LoadingProgress += 5
-	u.logger.Trace(fmt.Sprintf("Loading-%d - %s", params.LoadingProgress, params.InputFullFileName)) // ✅ 95% - Merging done, starting cleanup
+	progress.SetStage(constants.ProgressStageCleanup, 95) // ✅ 95% - Merging done, starting cleanup
 
 	u.logger.Info("🧹 Cleaning temporary files")
 
 	// Cleanup temp batch videos
 	os.RemoveAll(params.TempDir)
 
-	params.LoadingProgress += 5
-	u.logger.Trace(fmt.Sprintf("Loading-%d - %s", params.LoadingProgress, params.InputFullFileName)) // ✅ 100% - Process complete
+	progress.SetStage(constants.ProgressStageDone, 100) // ✅ 100% - Process complete
 	totalElapsed := time.Since(startTime).Seconds()
-	u.logger.Info(fmt.Sprintf("✅ Upscaling completed! Took: %dm%.2fs! 📊 Frames: %d | FPS: %d | Model: %s | Scale: %dx | video height: %d | video width: %d", int(totalElapsed/60), totalElapsed, videoMetaData.FPS, videoMetaData.TotalFrames, params.Model, params.ScaleMultiplier, videoMetaData.Height, videoMetaData.Width))
+	u.logger.Info(fmt.Sprintf("✅ Upscaling completed! Took: %dm%.2fs! 📊 Frames: %d | FPS: %s (%s) | Model: %s | Pre-scale: %.2fx (%s) | Scale: %dx | output: %dx%d", int(totalElapsed/60), math.Mod(totalElapsed, 60), totalFrames, rationalString(params.FrameRate), params.FrameRateMode, describePasses(params.Plan.Passes), params.Plan.PreScaleFactor, params.Plan.PreScalePolicy, params.ScaleMultiplier, params.Plan.OutputWidth, params.Plan.OutputHeight))
 
 	return nil
 }
@@ -0,0 +1,292 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	config "github.com/riskibarqy/RevivePixels/backend/confiig"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/engine"
+)
+
+// requireFFmpeg points the config at the ffmpeg and ffprobe of the PATH, skipping the test without them.
+func requireFFmpeg(t *testing.T) {
+	t.Helper()
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not found")
+	}
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		t.Skip("ffprobe not found")
+	}
+	config.Paths.FFmpegPath, config.Paths.FFprobePath = ffmpegPath, ffprobePath
+}
+
+// numberedClip encodes a clip whose frame N is a flat gray of luma 16+4N, so
+// every extracted frame tells its index. Keyframes are 10 frames apart with
+// B-frames in between, a batch start is rarely a keyframe. With audioFirst the
+// file starts with a silent audio track and the first frame comes 0.3s later.
+func numberedClip(t *testing.T, frames int, audioFirst bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "numbered.mkv")
+	video := fmt.Sprintf("color=black:size=32x32:rate=25,format=yuv420p,geq=lum=16+4*N:cb=128:cr=128,trim=end_frame=%d", frames)
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", "lavfi", "-i", video, "-map", "0:v"}
+	if audioFirst {
+		args = []string{"-hide_banner", "-loglevel", "error",
+			"-f", "lavfi", "-i", "anullsrc=r=48000:cl=mono",
+			"-itsoffset", "0.3", "-f", "lavfi", "-i", video,
+			"-map", "1:v", "-map", "0:a", "-c:a", "pcm_s16le", "-shortest",
+		}
+	}
+	args = append(args, "-c:v", "mpeg4", "-q:v", "1", "-g", "10", "-bf", "2", path)
+
+	if output, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg failed: %v\n%s", err, output)
+	}
+	return path
+}
+
+// frameNumber reads back the index of a frame written by numberedClip.
+func frameNumber(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := img.Bounds()
+	gray := color.GrayModel.Convert(img.At(bounds.Dx()/2, bounds.Dy()/2)).(color.Gray)
+	// Back from full range RGB to the limited range luma of the clip
+	return int(math.Round(float64(gray.Y) * 219 / 255 / 4))
+}
+
+func TestExtractVideoFramesBatchBoundaries(t *testing.T) {
+	requireFFmpeg(t)
+
+	const totalFrames = 48
+	tests := []struct {
+		name       string
+		audioFirst bool // the first frame is not at the start of the file
+		timestamps bool // seek by the probed frame timestamps, as in vfr mode
+	}{
+		{name: "starts at zero"},
+		{name: "starts at zero by timestamps", timestamps: true},
+		{name: "video starts after audio", audioFirst: true},
+		{name: "video starts after audio by timestamps", audioFirst: true, timestamps: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clip := numberedClip(t, totalFrames, tt.audioFirst)
+
+			u := NewVideoUpscaler(newTestLogger(t), &sync.Map{}).(*videoUpscalerUsecase)
+			ctx := context.Background()
+			videoMetadata, err := u.GetVideoMetadata(ctx, clip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if videoMetadata.TotalFrames != totalFrames {
+				t.Fatalf("probed %d frames, want %d", videoMetadata.TotalFrames, totalFrames)
+			}
+			if tt.audioFirst && math.Abs(videoMetadata.StartOffset-0.3) > 0.01 {
+				t.Fatalf("start offset = %.3f, want 0.3", videoMetadata.StartOffset)
+			}
+			if tt.timestamps {
+				if videoMetadata.FrameTimestamps, err = u.probeFrameTimestamps(ctx, clip); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plan := &datatransfers.ResolutionPlan{
+				SourceWidth: videoMetadata.Width, SourceHeight: videoMetadata.Height,
+				InputWidth: videoMetadata.Width, InputHeight: videoMetadata.Height,
+			}
+
+			var got []int
+			for _, batch := range splitBatches(0, totalFrames, nil, 1, 13) {
+				frameDir := t.TempDir()
+				frameCount := batch.EndFrame - batch.StartFrame + 1
+				if err := u.ExtractVideoFrames(ctx, frameDir, clip, batch.StartFrame, frameCount, plan, videoMetadata); err != nil {
+					t.Fatal(err)
+				}
+
+				frames, _ := filepath.Glob(filepath.Join(frameDir, "*.png"))
+				sort.Strings(frames)
+				if len(frames) != frameCount {
+					t.Errorf("batch %d-%d extracted %d frames, want %d", batch.StartFrame, batch.EndFrame, len(frames), frameCount)
+				}
+				for _, frame := range frames {
+					got = append(got, frameNumber(t, frame))
+				}
+			}
+
+			for i, number := range got {
+				if number != i {
+					t.Fatalf("frame %d of the joined batches is source frame %d, want %d (all: %v)", i, number, i, got)
+				}
+			}
+			if len(got) != totalFrames {
+				t.Fatalf("joined batches have %d frames, want %d", len(got), totalFrames)
+			}
+		})
+	}
+}
+
+// frameEngine hides the directory mode of an engine, it is run once per frame.
+type frameEngine struct {
+	engine.UpscaleEngine
+}
+
+func (e frameEngine) Capabilities() engine.Capabilities {
+	capabilities := e.UpscaleEngine.Capabilities()
+	capabilities.SupportsDirectory = false
+	return capabilities
+}
+
+// writeFrames writes count png frames of width x height to dir, named like extracted frames.
+func writeFrames(t *testing.T, dir string, count, width, height int) []string {
+	t.Helper()
+	var frames []string
+	for i := 1; i <= count; i++ {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for p := range img.Pix {
+			img.Pix[p] = uint8(i * 10)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i))
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(file, img); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		frames = append(frames, path)
+	}
+	return frames
+}
+
+func TestUpscaleFrames(t *testing.T) {
+	tests := []struct {
+		name      string
+		perFrame  bool
+		passes    []datatransfers.UpscalePass
+		wantScale int
+	}{
+		{name: "directory mode", passes: []datatransfers.UpscalePass{{Model: engine.FakeModel, Scale: 2}}, wantScale: 2},
+		{name: "per frame", perFrame: true, passes: []datatransfers.UpscalePass{{Model: engine.FakeModel, Scale: 3}}, wantScale: 3},
+		{name: "chained passes", passes: []datatransfers.UpscalePass{{Model: engine.FakeModel, Scale: 2}, {Model: engine.FakeModel, Scale: 3}}, wantScale: 6},
+		{name: "chained passes per frame", perFrame: true, passes: []datatransfers.UpscalePass{{Model: engine.FakeModel, Scale: 2}, {Model: engine.FakeModel, Scale: 2}}, wantScale: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upscaleEngine := engine.NewFake()
+			if tt.perFrame {
+				upscaleEngine = frameEngine{upscaleEngine}
+			}
+			u := NewVideoUpscalerWithEngine(newTestLogger(t), &sync.Map{}, upscaleEngine).(*videoUpscalerUsecase)
+
+			tempDir := t.TempDir()
+			frameDir := filepath.Join(tempDir, "batch_00000")
+			if err := os.Mkdir(frameDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			frames := writeFrames(t, frameDir, 5, 8, 6)
+
+			params := &datatransfers.VideoUpscalerRequest{TempDir: tempDir, Concurrency: 2, Plan: datatransfers.ResolutionPlan{Passes: tt.passes}}
+			if err := u.UpscaleFrames(context.Background(), frames, frameDir, params); err != nil {
+				t.Fatal(err)
+			}
+
+			upscaled, _ := filepath.Glob(filepath.Join(upscaledFrameDir(frameDir), "*.png"))
+			sort.Strings(upscaled)
+			if len(upscaled) != len(frames) {
+				t.Fatalf("%d upscaled frames, want %d", len(upscaled), len(frames))
+			}
+			for i, path := range upscaled {
+				if filepath.Base(path) != filepath.Base(frames[i]) {
+					t.Errorf("upscaled frame %s, want %s", filepath.Base(path), filepath.Base(frames[i]))
+				}
+				file, err := os.Open(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				size, err := png.DecodeConfig(file)
+				file.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if size.Width != 8*tt.wantScale || size.Height != 6*tt.wantScale {
+					t.Errorf("%s is %dx%d, want %dx%d", filepath.Base(path), size.Width, size.Height, 8*tt.wantScale, 6*tt.wantScale)
+				}
+			}
+
+			// Only the source frames and the final pass are left
+			if dirs, _ := filepath.Glob(filepath.Join(tempDir, "*")); len(dirs) != 2 {
+				t.Errorf("temp dir holds %v, want the batch and its upscaled frames", dirs)
+			}
+		})
+	}
+}
+
+func TestUpscaleVideoWithFakeEngine(t *testing.T) {
+	requireFFmpeg(t)
+
+	const totalFrames = 40
+	tests := []struct {
+		name   string
+		params datatransfers.VideoUpscalerRequest
+	}{
+		{name: "batches", params: datatransfers.VideoUpscalerRequest{Model: engine.FakeModel, ScaleMultiplier: 2}},
+		{name: "streaming", params: datatransfers.VideoUpscalerRequest{Model: engine.FakeModel, ScaleMultiplier: 2, Streaming: true}},
+		{name: "chained passes", params: datatransfers.VideoUpscalerRequest{Passes: []datatransfers.UpscalePass{{Model: engine.FakeModel, Scale: 1}, {Model: engine.FakeModel, Scale: 2}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clip := numberedClip(t, totalFrames, true)
+			params, err := NewFileRequest(clip, t.TempDir(), t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			params.Model, params.ScaleMultiplier, params.Passes, params.Streaming = tt.params.Model, tt.params.ScaleMultiplier, tt.params.Passes, tt.params.Streaming
+			params.Container, params.Encoder = "mkv", datatransfers.EncoderOptions{Codec: "ffv1"}
+
+			u := NewVideoUpscalerWithEngine(newTestLogger(t), &sync.Map{}, engine.NewFake()).(*videoUpscalerUsecase)
+			ctx := context.Background()
+			if err := u.UpscaleVideoWithRealESRGAN(ctx, params); err != nil {
+				t.Fatal(err)
+			}
+
+			videoMetadata, err := u.GetVideoMetadata(ctx, params.SavePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if videoMetadata.Width != 64 || videoMetadata.Height != 64 || videoMetadata.TotalFrames != totalFrames {
+				t.Errorf("output is %dx%d with %d frames, want 64x64 with %d", videoMetadata.Width, videoMetadata.Height, videoMetadata.TotalFrames, totalFrames)
+			}
+			if _, err := os.Stat(params.TempDir); !os.IsNotExist(err) {
+				t.Errorf("job dir %s was not cleaned up: %v", params.TempDir, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/riskibarqy/RevivePixels/backend"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+const (
+	cliCommandUpscale = "upscale"
+	cliCommandServe   = "serve"
+	cliCommandWatch   = "watch"
+)
+
+// Exit codes of the headless mode
+const (
+	exitOK            = 0
+	exitUpscaleFailed = 1
+	exitUsage         = 2
+	exitSetupFailed   = 3
+	exitInputNotFound = 4
+	exitInputFailed   = 5 // the input can't be probed or decoded
+	exitEngineFailed  = 6
+	exitEncodeFailed  = 7 // encoding or muxing the output failed
+	exitCancelled     = 130
+)
+
+// cliCommands maps the headless subcommands to their entry points.
+func (u *App) cliCommands() map[string]func(args []string) int {
+	return map[string]func(args []string) int{
+		cliCommandUpscale: u.runCLI,
+		cliCommandServe:   u.runServeCLI,
+		cliCommandWatch:   u.runWatchCLI,
+	}
+}
+
+// runCLI drives the upscaler from the command line without starting a window:
+//
+//	revivepixels upscale --model realesr-animevideov3 --scale 2 --out ./out input1.mp4 input2.mkv
+//	revivepixels upscale --preset "Anime 2x fast" input.mp4
+func (u *App) runCLI(args []string) int {
+	attachConsole()
+
+	flags := flag.NewFlagSet(cliCommandUpscale, flag.ContinueOnError)
+	preset := flags.String("preset", "", "saved preset to use, --model, --scale and --streaming override it")
+	model := flags.String("model", "realesr-animevideov3", "upscale model")
+	scale := flags.Int("scale", 2, "scale multiplier")
+	outputFolder := flags.String("out", "", "output folder (default: the configured one)")
+	streaming := flags.Bool("streaming", false, "pipe raw frames instead of writing png files, ffmpeg models only")
+	var encoder datatransfers.EncoderOptions
+	flags.StringVar(&encoder.Codec, "codec", "", "video encoder: libx264, libx265, libsvtav1, libaom-av1, libvpx-vp9 or ffv1 (default: the configured one)")
+	flags.Func("crf", "constant rate factor, 0 is lossless with libx264 (default: the encoder default)", func(value string) error {
+		crf, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		encoder.CRF = &crf
+		return nil
+	})
+	flags.StringVar(&encoder.Bitrate, "bitrate", "", "target bitrate, e.g. 8M, instead of crf")
+	flags.StringVar(&encoder.Preset, "encoder-preset", "", "encoder speed preset, e.g. slow")
+	flags.StringVar(&encoder.Tune, "tune", "", "encoder tune, e.g. animation")
+	flags.StringVar(&encoder.PixelFormat, "pix-fmt", "", "pixel format, e.g. yuv420p10le for 10-bit")
+	audioCodec := flags.String("audio-codec", "", "encoder of the audio tracks the container can't hold as they are, e.g. libopus (default: the container default)")
+	audioBitrate := flags.String("audio-bitrate", "", "bitrate of re-encoded audio, e.g. 192k")
+	frameRateMode := flags.String("fps-mode", "", "frame timing: auto, cfr or vfr to keep the source timestamps (default: auto)")
+	passes := flags.String("passes", "", "chain models on the same frames instead of --model and --scale, e.g. realesr-animevideov3-x2,realesrgan-x4plus:4")
+	sceneThreshold := flags.Float64("scene-threshold", 0, "scene score starting a new batch, between 0 and 1 (default: 0.3)")
+	fixedBatches := flags.Bool("fixed-batches", false, "cut batches every 150 frames instead of on scene changes")
+	fit := flags.String("fit", "", "upscale to fit within WxH, e.g. 1920x1080, instead of --scale")
+	height := flags.Int("height", 0, "upscale to this height, e.g. 2160, instead of --scale")
+	exact := flags.String("exact", "", "upscale to exactly WxH, padded or cropped, instead of --scale")
+	fill := flags.String("fill", "pad", "how --exact fills another aspect ratio: pad or crop")
+	preScale := flags.String("prescale", "", "shrink frames before upscaling: auto, none, factor or max_dimension (default: auto, picked per model)")
+	preScaleFactor := flags.Float64("prescale-factor", 0, "factor of --prescale factor, e.g. 0.5")
+	preScaleMax := flags.Int("prescale-max", 0, "longest side of --prescale max_dimension, e.g. 1280")
+	startTime := flags.String("start", "", "upscale from this time, e.g. 90.5 or 00:01:30.5")
+	endTime := flags.String("end", "", "upscale up to this time")
+	startFrame := flags.Int("start-frame", 0, "upscale from this frame, when --start is not set")
+	endFrame := flags.Int("end-frame", 0, "upscale up to this frame, excluded, when --end is not set")
+	container := flags.String("container", "", "output container: mp4, mkv, mov or webm (default: the configured one)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [flags] input...\n", filepath.Base(os.Args[0]), cliCommandUpscale)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	inputs := flags.Args()
+	for _, input := range inputs {
+		if _, err := os.Stat(input); err != nil {
+			fmt.Fprintf(os.Stderr, "input not found: %s\n", input)
+			return exitInputNotFound
+		}
+	}
+
+	if err := u.setupTools(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up tools: %v\n", err)
+		return exitSetupFailed
+	}
+	u.pruneJobDirs()
+
+	if *outputFolder == "" {
+		folder, err := u.outputFolder()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create output folder: %v\n", err)
+			return exitSetupFailed
+		}
+		*outputFolder = folder
+	} else if err := os.MkdirAll(*outputFolder, os.ModePerm); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create output folder: %v\n", err)
+		return exitSetupFailed
+	}
+
+	jobsFolder, err := u.jobsFolder()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create jobs folder: %v\n", err)
+		return exitSetupFailed
+	}
+
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	// Every input is checked before the first job starts, exiting on a bad one
+	// would kill the jobs already running
+	requests := make([]*datatransfers.VideoUpscalerRequest, 0, len(inputs))
+	discard := func() {
+		for _, request := range requests {
+			os.RemoveAll(request.TempDir)
+		}
+	}
+	for _, input := range inputs {
+		// The input is read in place, only the job data goes to the job dir
+		request, err := backend.NewFileRequest(input, jobsFolder, *outputFolder)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to queue %s: %v\n", input, err)
+			discard()
+			return exitSetupFailed
+		}
+		requests = append(requests, request)
+		request.StartTime, request.EndTime = *startTime, *endTime
+		request.StartFrame, request.EndFrame = *startFrame, *endFrame
+		request.SceneThreshold, request.FixedBatches = *sceneThreshold, *fixedBatches
+		if err := u.applyRequestDefaults(request, *preset); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to queue %s: %v\n", input, err)
+			discard()
+			return exitUsage
+		}
+		if *preset == "" || explicit["model"] {
+			request.Model = *model
+		}
+		if *preset == "" || explicit["scale"] {
+			request.ScaleMultiplier = *scale
+			request.Target = datatransfers.TargetResolution{}
+		}
+		if explicit["model"] || explicit["scale"] {
+			request.Passes = nil
+		}
+		if explicit["passes"] {
+			if request.Passes, err = passesFromFlag(*passes); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid passes: %v\n", err)
+				discard()
+				return exitUsage
+			}
+		}
+		if explicit["prescale"] {
+			request.PreScale = datatransfers.PreScaleOptions{
+				Policy:       constants.PreScalePolicy(*preScale),
+				Factor:       *preScaleFactor,
+				MaxDimension: *preScaleMax,
+			}
+		}
+		if target, ok, err := targetFromFlags(*fit, *height, *exact, *fill); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid target: %v\n", err)
+			discard()
+			return exitUsage
+		} else if ok {
+			request.Target = target
+		}
+		if *preset == "" || explicit["streaming"] {
+			request.Streaming = *streaming
+		}
+		overrideEncoder(&request.Encoder, encoder, explicit)
+		if explicit["fps-mode"] {
+			request.FrameRateMode = constants.FrameRateMode(*frameRateMode)
+		}
+		if explicit["audio-codec"] {
+			request.Audio.Codec = *audioCodec
+		}
+		if explicit["audio-bitrate"] {
+			request.Audio.Bitrate = *audioBitrate
+		}
+		if explicit["container"] {
+			request.Container = *container
+		}
+		if err := u.validateRequest(request); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to queue %s: %v\n", input, err)
+			discard()
+			return exitUsage
+		}
+	}
+
+	// Ctrl+C cancels the running jobs instead of killing the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	u.ctx = ctx
+	u.jobManager.Start(ctx)
+
+	events, unsubscribe := u.jobManager.Subscribe()
+	defer unsubscribe()
+	go printProgress(events)
+
+	jobIDs := make([]string, 0, len(requests))
+	for _, request := range requests {
+		jobIDs = append(jobIDs, u.jobManager.Submit(request))
+	}
+
+	// A cancel wins, otherwise the first failure gives the exit code
+	exitCode := exitOK
+	for _, jobID := range jobIDs {
+		info, err := u.jobManager.Wait(jobID)
+		code := jobExitCode(info)
+		if err != nil {
+			code = exitUpscaleFailed
+		}
+		switch code {
+		case exitOK:
+			fmt.Printf("\n✔ %s -> %s\n", info.FileName, info.SavePath)
+		case exitCancelled:
+			fmt.Printf("\n✖ %s: cancelled\n", info.FileName)
+		default:
+			fmt.Printf("\n✖ %s: %s\n", info.FileName, info.Error)
+		}
+		if exitCode == exitOK || code == exitCancelled {
+			exitCode = code
+		}
+	}
+
+	return exitCode
+}
+
+// jobExitCode maps the final state of a job to the exit code of the headless mode.
+func jobExitCode(info datatransfers.JobInfo) int {
+	switch info.State {
+	case constants.JobStateDone:
+		return exitOK
+	case constants.JobStateCancelled:
+		return exitCancelled
+	}
+
+	switch info.Failure {
+	case constants.JobFailureInput:
+		return exitInputFailed
+	case constants.JobFailureEngine:
+		return exitEngineFailed
+	case constants.JobFailureEncode:
+		return exitEncodeFailed
+	}
+	return exitUpscaleFailed
+}
+
+// targetFromFlags returns the target resolution given with --fit, --height or
+// --exact, it reports false when none was given.
+func targetFromFlags(fit string, height int, exact, fill string) (datatransfers.TargetResolution, bool, error) {
+	var target datatransfers.TargetResolution
+	given := 0
+	if fit != "" {
+		target.Mode = constants.TargetModeFit
+		given++
+	}
+	if height > 0 {
+		target = datatransfers.TargetResolution{Mode: constants.TargetModeHeight, Height: height}
+		given++
+	}
+	if exact != "" {
+		target.Mode = constants.TargetModeExact
+		target.Fill = constants.TargetFill(fill)
+		given++
+	}
+	if given == 0 {
+		return target, false, nil
+	}
+	if given > 1 {
+		return target, false, fmt.Errorf("only one of --fit, --height and --exact can be used")
+	}
+
+	if size := fit + exact; size != "" {
+		width, height, ok := strings.Cut(strings.ToLower(size), "x")
+		var errWidth, errHeight error
+		target.Width, errWidth = strconv.Atoi(width)
+		target.Height, errHeight = strconv.Atoi(height)
+		if !ok || errWidth != nil || errHeight != nil {
+			return target, false, fmt.Errorf("invalid size %q, expected WxH e.g. 1920x1080", size)
+		}
+	}
+	return target, true, backend.ValidateTarget(target)
+}
+
+// passesFromFlag parses --passes, a comma separated list of model:scale, the
+// scale may be left out to use the largest one of the model.
+func passesFromFlag(value string) ([]datatransfers.UpscalePass, error) {
+	var passes []datatransfers.UpscalePass
+	for _, item := range strings.Split(value, ",") {
+		model, scale, hasScale := strings.Cut(strings.TrimSpace(item), ":")
+		pass := datatransfers.UpscalePass{Model: model}
+		if hasScale {
+			var err error
+			if pass.Scale, err = strconv.Atoi(scale); err != nil {
+				return nil, fmt.Errorf("invalid pass %q, expected model:scale e.g. realesrgan-x4plus:4", item)
+			}
+		}
+		passes = append(passes, pass)
+	}
+	return passes, backend.ValidatePasses(passes)
+}
+
+// overrideEncoder applies the encoder flags given on the command line.
+// Changing the codec drops the options of the previous one.
+func overrideEncoder(target *datatransfers.EncoderOptions, flagValues datatransfers.EncoderOptions, explicit map[string]bool) {
+	if explicit["codec"] {
+		*target = datatransfers.EncoderOptions{Codec: flagValues.Codec}
+	}
+	for name, apply := range map[string]func(){
+		"crf":            func() { target.CRF = flagValues.CRF },
+		"bitrate":        func() { target.Bitrate = flagValues.Bitrate },
+		"encoder-preset": func() { target.Preset = flagValues.Preset },
+		"tune":           func() { target.Tune = flagValues.Tune },
+		"pix-fmt":        func() { target.PixelFormat = flagValues.PixelFormat },
+	} {
+		if explicit[name] {
+			apply()
+		}
+	}
+}
+
+// runServeCLI runs the loopback HTTP API without a window until interrupted:
+//
+//	revivepixels serve --port 8765 --token secret
+func (u *App) runServeCLI(args []string) int {
+	attachConsole()
+
+	flags := flag.NewFlagSet(cliCommandServe, flag.ContinueOnError)
+	port := flags.Int("port", 8765, "port to listen on, 127.0.0.1 only")
+	token := flags.String("token", os.Getenv("REVIVEPIXELS_API_TOKEN"), "API token (default: $REVIVEPIXELS_API_TOKEN or a random one)")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if err := u.setupTools(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up tools: %v\n", err)
+		return exitSetupFailed
+	}
+	u.pruneJobDirs()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	u.ctx = ctx
+	u.jobManager.Start(ctx)
+
+	status, err := u.StartAPIServer(*port, *token)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start API server: %v\n", err)
+		return exitSetupFailed
+	}
+	fmt.Printf("Listening on %s\nToken: %s\n", status.Address, status.Token)
+
+	<-ctx.Done()
+	u.StopAPIServer()
+	return exitOK
+}
+
+// runWatchCLI enqueues every video dropped into a folder until interrupted:
+//
+//	revivepixels watch --model realesr-animevideov3 --scale 2 --out ./out ./incoming
+func (u *App) runWatchCLI(args []string) int {
+	attachConsole()
+
+	flags := flag.NewFlagSet(cliCommandWatch, flag.ContinueOnError)
+	preset := flags.String("preset", "", "saved preset to use instead of --model, --scale and --streaming")
+	model := flags.String("model", "realesr-animevideov3", "upscale model")
+	scale := flags.Int("scale", 2, "scale multiplier")
+	outputFolder := flags.String("out", "", "output folder (default: the configured one)")
+	streaming := flags.Bool("streaming", false, "pipe raw frames instead of writing png files, ffmpeg models only")
+	interval := flags.Int("interval", 5, "seconds between two scans of the folder")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [flags] folder\n", filepath.Base(os.Args[0]), cliCommandWatch)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if err := u.setupTools(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up tools: %v\n", err)
+		return exitSetupFailed
+	}
+	u.pruneJobDirs()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	u.ctx = ctx
+	u.jobManager.Start(ctx)
+
+	events, unsubscribe := u.jobManager.Subscribe()
+	defer unsubscribe()
+	go printProgress(events)
+
+	watchConfig, err := u.StartWatchFolder(datatransfers.WatchFolderConfig{
+		Folder:              flags.Arg(0),
+		Preset:              *preset,
+		Model:               *model,
+		Scale:               *scale,
+		OutputFolder:        *outputFolder,
+		Streaming:           *streaming,
+		PollIntervalSeconds: *interval,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to watch folder: %v\n", err)
+		return exitInputNotFound
+	}
+	fmt.Printf("Watching %s, results go to %s\n", watchConfig.Folder, watchConfig.OutputFolder)
+
+	// Let the cancelled jobs settle so no original is left half moved
+	<-ctx.Done()
+	if watcher := u.stopWatchFolder(); watcher != nil {
+		watcher.Wait()
+	}
+	return exitOK
+}
+
+// printProgress renders the progress events on a single terminal line.
+func printProgress(events <-chan datatransfers.ProgressEvent) {
+	for event := range events {
+		line := fmt.Sprintf("%s: %-16s %5.1f%%", event.FileName, event.Stage, event.Percent)
+		if event.Stage == constants.ProgressStageUpscaling {
+			line += fmt.Sprintf(" | batch %d/%d | %d/%d frames | %.1f fps | ETA %s",
+				event.Batch, event.TotalBatches, event.FramesDone, event.TotalFrames, event.FPS,
+				(time.Duration(event.ETASeconds) * time.Second).Round(time.Second))
+		}
+		fmt.Printf("\r\033[K%s", line)
+	}
+}
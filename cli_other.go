@@ -0,0 +1,6 @@
+//go:build !windows
+
+package main
+
+// attachConsole is only needed for the Windows GUI subsystem binary.
+func attachConsole() {}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+)
+
+func TestJobExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   constants.JobState
+		failure constants.JobFailure
+		want    int
+	}{
+		{"done", constants.JobStateDone, constants.JobFailureOther, exitOK},
+		{"cancelled", constants.JobStateCancelled, constants.JobFailureOther, exitCancelled},
+		{"input", constants.JobStateFailed, constants.JobFailureInput, exitInputFailed},
+		{"engine", constants.JobStateFailed, constants.JobFailureEngine, exitEngineFailed},
+		{"encode", constants.JobStateFailed, constants.JobFailureEncode, exitEncodeFailed},
+		{"other", constants.JobStateFailed, constants.JobFailureOther, exitUpscaleFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobExitCode(datatransfers.JobInfo{State: tt.state, Failure: tt.failure}); got != tt.want {
+				t.Errorf("jobExitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPassesFromFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []datatransfers.UpscalePass
+		wantErr bool
+	}{
+		{value: "realesr-animevideov3-x2,realesrgan-x4plus:4", want: []datatransfers.UpscalePass{{Model: "realesr-animevideov3-x2"}, {Model: "realesrgan-x4plus", Scale: 4}}},
+		{value: " realesr-animevideov3:3 ", want: []datatransfers.UpscalePass{{Model: "realesr-animevideov3", Scale: 3}}},
+		{value: "realesrgan-x4plus:four", wantErr: true},
+		{value: "realesrgan-x4plus,:2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := passesFromFlag(tt.value)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !slices.Equal(got, tt.want)) {
+			t.Errorf("passesFromFlag(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestOverrideEncoder(t *testing.T) {
+	configured, lossless := 18, 0
+	tests := []struct {
+		name     string
+		flags    datatransfers.EncoderOptions
+		explicit map[string]bool
+		want     datatransfers.EncoderOptions
+	}{
+		{
+			name: "no flags",
+			want: datatransfers.EncoderOptions{Codec: "libx264", CRF: &configured, Preset: "slow"},
+		},
+		{
+			name:     "crf zero",
+			flags:    datatransfers.EncoderOptions{CRF: &lossless},
+			explicit: map[string]bool{"crf": true},
+			want:     datatransfers.EncoderOptions{Codec: "libx264", CRF: &lossless, Preset: "slow"},
+		},
+		{
+			name:     "codec drops the configured options",
+			flags:    datatransfers.EncoderOptions{Codec: "ffv1"},
+			explicit: map[string]bool{"codec": true},
+			want:     datatransfers.EncoderOptions{Codec: "ffv1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := datatransfers.EncoderOptions{Codec: "libx264", CRF: &configured, Preset: "slow"}
+			overrideEncoder(&target, tt.flags, tt.explicit)
+			if !reflect.DeepEqual(target, tt.want) {
+				t.Errorf("encoder = %+v, want %+v", target, tt.want)
+			}
+		})
+	}
+}
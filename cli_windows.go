@@ -0,0 +1,27 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// attachConsole connects the GUI subsystem binary to the console it was started
+// from, otherwise nothing printed by the headless mode would be visible.
+func attachConsole() {
+	if _, err := os.Stdout.Stat(); err == nil {
+		return // output is already redirected somewhere
+	}
+
+	const attachParentProcess = ^uintptr(0) // (DWORD)-1
+	attach := syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+	if ok, _, _ := attach.Call(attachParentProcess); ok == 0 {
+		return
+	}
+
+	if console, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = console
+		os.Stderr = console
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !(windows && amd64)
+
+package main
+
+import (
+	"embed"
+
+	"github.com/riskibarqy/RevivePixels/backend/tools"
+)
+
+// No binaries are bundled for this platform, the tools are found through the
+// configured paths or $PATH.
+var embeddedTools embed.FS
+
+var embeddedToolPaths = map[tools.Tool]string{}
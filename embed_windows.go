@@ -0,0 +1,18 @@
+//go:build windows && amd64
+
+package main
+
+import (
+	"embed"
+
+	"github.com/riskibarqy/RevivePixels/backend/tools"
+)
+
+//go:embed embeds/ffmpeg/ffmpeg.exe embeds/ffmpeg/ffprobe.exe embeds/realesrgan/realesrgan-ncnn-vulkan.exe
+var embeddedTools embed.FS
+
+var embeddedToolPaths = map[tools.Tool]string{
+	tools.FFmpeg:     "embeds/ffmpeg/ffmpeg.exe",
+	tools.FFprobe:    "embeds/ffmpeg/ffprobe.exe",
+	tools.RealEsrgan: "embeds/realesrgan/realesrgan-ncnn-vulkan.exe",
+}
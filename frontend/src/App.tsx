@@ -38,17 +38,6 @@ function App() {
 
     useEffect(() => {
         const handler = (log: string) => {
-            const match = log.match(/Loading-(\d+)\s*-\s*(.+)/);
-            if (match) {
-                const percentage = parseInt(match[1], 10);
-                const fileName = match[2].trim();
-                setProgressMap((prev) => ({
-                    ...prev,
-                    [fileName]: percentage
-                }));
-                return;
-            }
-
             setLogs((prevLogs) => [...prevLogs, log]);
         };
 
@@ -56,6 +45,18 @@ function App() {
         return () => EventsOff("stderr_log", handler as unknown as string);
     }, []);
 
+    useEffect(() => {
+        const handler = (event: { fileName: string; percent: number }) => {
+            setProgressMap((prev) => ({
+                ...prev,
+                [event.fileName]: Math.floor(event.percent)
+            }));
+        };
+
+        EventsOn("job_progress", handler);
+        return () => EventsOff("job_progress", handler as unknown as string);
+    }, []);
+
     useEffect(() => {
         if (logContainerRef.current) {
             const viewport = logContainerRef.current.querySelector('[data-radix-scroll-area-viewport]');
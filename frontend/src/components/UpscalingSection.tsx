@@ -40,6 +40,10 @@ const UPSCALE_MODELS = [
     { name: "realesrnet-x4plus", scales: [4] },
     { name: "realesrgan-x4plus-anime", scales: [4] },
     { name: "realesr-animevideov3", scales: [2, 3, 4] },
+    { name: "ffmpeg-lanczos", scales: [2, 3, 4] },
+    { name: "ffmpeg-lanczos-sharp", scales: [2, 3, 4] },
+    { name: "ffmpeg-spline", scales: [2, 3, 4] },
+    { name: "ffmpeg-spline-sharp", scales: [2, 3, 4] },
 ];
 
 export function UpscalingSection({
@@ -6,19 +6,26 @@ import (
 	"embed"
 	"encoding/base64"
 	"fmt"
+	"io/fs"
 	"log"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"runtime"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/riskibarqy/RevivePixels/backend"
+	"github.com/riskibarqy/RevivePixels/backend/api"
 	config "github.com/riskibarqy/RevivePixels/backend/confiig"
 	"github.com/riskibarqy/RevivePixels/backend/constants"
 	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/engine"
+	"github.com/riskibarqy/RevivePixels/backend/settings"
+	"github.com/riskibarqy/RevivePixels/backend/tools"
 	"github.com/riskibarqy/RevivePixels/backend/utils"
 
 	"os"
@@ -34,20 +41,30 @@ import (
 //go:embed all:frontend/dist
 var assets embed.FS
 
-//go:embed embeds/ffmpeg/ffmpeg.exe embeds/ffmpeg/ffprobe.exe
-var embeddedFFmpeg embed.FS
+// The tool binaries are embedded per platform, see embed_*.go
+//
+//go:embed embeds/realesrgan/models/*
+var embeddedModels embed.FS
 
-//go:embed embeds/realesrgan/realesrgan-ncnn-vulkan.exe embeds/realesrgan/models/*
-var embeddedRealEsrgan embed.FS
+const embeddedModelsDir = "embeds/realesrgan/models"
+
+//go:generate go run ./scripts/checksums
+//go:embed embeds/checksums.sha256
+var embeddedChecksums []byte
 
 var logger *utils.CustomLogger
-var cancelFunc context.CancelFunc
 
 // App struct
 type App struct {
-	ctx           context.Context
-	videoUpscaler backend.VideoUpscalerUsecase
-	sessionApps   *sync.Map // Store session data
+	ctx             context.Context
+	videoUpscaler   backend.VideoUpscalerUsecase
+	jobManager      *backend.JobManager
+	servicesMu      sync.Mutex // guards apiServer and folderWatcher, bindings run concurrently
+	apiServer       *api.Server
+	folderWatcher   *backend.FolderWatcher
+	toolDiagnostics []datatransfers.ToolDiagnostic
+	settings        *settings.Store
+	sessionApps     *sync.Map // Store session data
 }
 
 // NewApp creates a new App application struct
@@ -56,115 +73,51 @@ func NewApp() *App {
 	videoUpscaler := backend.NewVideoUpscaler(logger, sessionApps)
 	return &App{
 		videoUpscaler: videoUpscaler,
+		jobManager:    backend.NewJobManager(logger, videoUpscaler),
 		sessionApps:   sessionApps,
 	}
 }
 
-// Extracts ffmpeg & ffprobe to a temp directory
-func (u *App) ExtractFFmpeg() error {
-	tempDirRaw, ok := u.sessionApps.Load(constants.CtxKeyRootTempDir)
-	if !ok {
-		return fmt.Errorf("rootTempDir not found in session")
-	}
-
-	rootTempDir, ok := tempDirRaw.(string)
-	if !ok {
-		return fmt.Errorf("rootTempDir is not a string")
-	}
-
-	// Extract ffmpeg
-	ffmpegPath := filepath.Join(rootTempDir, "ffmpeg.exe")
-	if err := extractFileEmbedded(constants.FileTypeEmbedFFMPEG, "embeds/ffmpeg/ffmpeg.exe", ffmpegPath); err != nil {
-		return err
-	}
-
-	// Extract ffprobe
-	ffprobePath := filepath.Join(rootTempDir, "ffprobe.exe")
-	if err := extractFileEmbedded(constants.FileTypeEmbedFFMPEG, "embeds/ffmpeg/ffprobe.exe", ffprobePath); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// Extracts realersgan and its model to a temp directory
-func (u *App) ExtractRealEsrgan() error {
-	tempDirRaw, ok := u.sessionApps.Load(constants.CtxKeyRootTempDir)
-	if !ok {
-		return fmt.Errorf("rootTempDir not found in session")
-	}
-
-	rootTempDir, ok := tempDirRaw.(string)
-	if !ok {
-		return fmt.Errorf("rootTempDir is not a string")
-	}
-
-	// Extract Real-ESRGAN executable
-	esrganPath := filepath.Join(rootTempDir, "realesrgan-ncnn-vulkan.exe")
-	if err := extractFileEmbedded(constants.FileTypeEmbedRealesrgan, "embeds/realesrgan/realesrgan-ncnn-vulkan.exe", esrganPath); err != nil {
-		return err
-	}
-
-	// Extract model files
-	modelsDir := filepath.Join(rootTempDir, "models")
+// extractModels extracts the embedded Real-ESRGAN models to modelsDir, reusing
+// the intact files of a previous run. The default model is required, models
+// with missing files are only reported.
+func extractModels(modelsDir string, checksums tools.Checksums) error {
 	if err := os.MkdirAll(modelsDir, 0755); err != nil {
 		return err
 	}
 
-	modelFiles := []string{
-		"embeds/realesrgan/models/realesr-animevideov3-x2.bin",
-		"embeds/realesrgan/models/realesr-animevideov3-x2.param",
-		"embeds/realesrgan/models/realesr-animevideov3-x3.bin",
-		"embeds/realesrgan/models/realesr-animevideov3-x3.param",
-		"embeds/realesrgan/models/realesr-animevideov3-x4.bin",
-		"embeds/realesrgan/models/realesr-animevideov3-x4.param",
-		"embeds/realesrgan/models/realesrgan-x4plus-anime.bin",
-		"embeds/realesrgan/models/realesrgan-x4plus-anime.param",
-		"embeds/realesrgan/models/realesrgan-x4plus.bin",
-		"embeds/realesrgan/models/realesrgan-x4plus.param",
-		"embeds/realesrgan/models/realesrnet-x4plus.bin",
-		"embeds/realesrgan/models/realesrnet-x4plus.param",
-		"embeds/realesrgan/models/RealESRGANv2-animevideo-xsx2.bin",
-		"embeds/realesrgan/models/RealESRGANv2-animevideo-xsx2.param",
-		"embeds/realesrgan/models/RealESRGANv2-animevideo-xsx4.bin",
-		"embeds/realesrgan/models/RealESRGANv2-animevideo-xsx4.param",
-	}
-
-	for _, model := range modelFiles {
-		dst := filepath.Join(modelsDir, filepath.Base(model))
-		err := extractFileEmbedded(constants.FileTypeEmbedRealesrgan, model, dst)
-		if err != nil {
-			log.Printf("Failed to extract %s: %v", model, err)
-		} else {
-			log.Printf("Extracted model: %s", dst)
-		}
-	}
-
-	return nil
-}
+	var missingRequired []string
+	for _, model := range engine.RealEsrganModels {
+		var missing []string
+		for _, file := range engine.RealEsrganModelFiles(model) {
+			src := path.Join(embeddedModelsDir, file)
+			if _, err := fs.Stat(embeddedModels, src); err != nil {
+				missing = append(missing, file)
+				continue
+			}
 
-// Helper function to extract a file
-func extractFileEmbedded(filetype, src string, dst string) error {
-	var err error
-	var data []byte
+			extracted, err := tools.ExtractVerified(embeddedModels, src, filepath.Join(modelsDir, file), checksums, 0644)
+			if err != nil {
+				return err
+			}
+			if extracted {
+				logger.Debug(fmt.Sprintf("Extracted model file: %s", file))
+			}
+		}
 
-	switch filetype {
-	case constants.FileTypeEmbedFFMPEG:
-		data, err = embeddedFFmpeg.ReadFile(src)
-		if err != nil {
-			return fmt.Errorf("failed to read embedded file %s: %v", src, err)
+		if len(missing) == 0 {
+			continue
 		}
-	case constants.FileTypeEmbedRealesrgan:
-		data, err = embeddedRealEsrgan.ReadFile(src)
-		if err != nil {
-			return fmt.Errorf("failed to read embedded file %s: %v", src, err)
+		if model == engine.DefaultRealEsrganModel {
+			missingRequired = append(missingRequired, missing...)
+		} else {
+			logger.Warning(fmt.Sprintf("⚠️ Model %s is not usable, missing: %s", model, strings.Join(missing, ", ")))
 		}
 	}
 
-	if err := os.WriteFile(dst, data, 0755); err != nil {
-		return fmt.Errorf("failed to write file %s: %v", dst, err)
+	if len(missingRequired) > 0 {
+		return fmt.Errorf("required model files are missing from this build: %s", strings.Join(missingRequired, ", "))
 	}
-
 	return nil
 }
 
@@ -190,39 +143,75 @@ func (u *App) beforeClose(ctx context.Context) (prevent bool) {
 	}
 
 	if dialog == "Yes" {
-		u.CleanupRootTempFolder() // Cleanup temp before exiting
+		u.StopAPIServer()
+		u.StopWatchFolder()
 		return false
 	}
 	return true
 }
 
-func (u *App) startup(ctx context.Context) {
-	u.ctx = ctx
-
-	sessionId := utils.GetSessionValue(u.sessionApps, constants.CtxSessionID)
+// setupTools resolves the tools, extracting the embedded ones into the per-user
+// cache, and initializes their paths
+func (u *App) setupTools() error {
 	appName := utils.GetSessionValue(u.sessionApps, constants.CtxAppName)
 
-	tempDir, _ := os.MkdirTemp(os.TempDir(), fmt.Sprintf("%s-%s", appName, sessionId))
+	if err := u.loadSettings(appName); err != nil {
+		return err
+	}
 
-	u.sessionApps.Store(constants.CtxKeyRootTempDir, tempDir)
+	checksums, err := tools.ParseChecksums(embeddedChecksums)
+	if err != nil {
+		return fmt.Errorf("failed to read embedded checksums: %v", err)
+	}
 
-	err := u.ExtractFFmpeg()
+	// Extracted files are kept across launches, a new build gets a new dir
+	cacheDir, err := tools.CacheDir(appName, checksums.Version())
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to create cache dir: %v", err)
 	}
 
-	err = u.ExtractRealEsrgan()
+	resolver := tools.NewResolver(embeddedTools, embeddedToolPaths, checksums, cacheDir, tools.Overrides(u.currentSettings().ToolPaths))
+	diagnostics, err := resolver.ResolveAll(context.Background())
+	u.toolDiagnostics = diagnostics
+	logger.Info("🔧 Tools:\n" + tools.Report(diagnostics))
 	if err != nil {
-		log.Fatal("Failed to extract Real-ESRGAN:", err)
+		return err
 	}
 
-	u.sessionApps.Store(constants.CtxFFmpegPath, tempDir+"/ffmpeg.exe")
-	u.sessionApps.Store(constants.CtxFFprobePath, tempDir+"/ffprobe.exe")
-	u.sessionApps.Store(constants.CtxRealesrganPath, tempDir+"/realesrgan-ncnn-vulkan.exe")
+	modelsDir := filepath.Join(cacheDir, "models")
+	if err := extractModels(modelsDir, checksums); err != nil {
+		return fmt.Errorf("failed to extract Real-ESRGAN models: %v", err)
+	}
+
+	paths := make(map[string]string, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Error == "" {
+			paths[diagnostic.Tool] = diagnostic.Path
+		}
+	}
+	u.sessionApps.Store(constants.CtxFFmpegPath, paths[string(tools.FFmpeg)])
+	u.sessionApps.Store(constants.CtxFFprobePath, paths[string(tools.FFprobe)])
+	u.sessionApps.Store(constants.CtxRealesrganPath, paths[string(tools.RealEsrgan)])
+	u.sessionApps.Store(constants.CtxModelsDir, modelsDir)
 
 	if err := config.InitializePaths(u.sessionApps); err != nil {
-		log.Fatal("Failed to initialize paths:", err)
+		return fmt.Errorf("failed to initialize paths: %v", err)
 	}
+	return nil
+}
+
+func (u *App) startup(ctx context.Context) {
+	u.ctx = ctx
+
+	if err := u.setupTools(); err != nil {
+		wailsRuntime.MessageDialog(ctx, wailsRuntime.MessageDialogOptions{
+			Type:    wailsRuntime.ErrorDialog,
+			Title:   "Startup failed",
+			Message: err.Error(),
+		})
+		log.Fatal(err)
+	}
+	u.pruneJobDirs()
 
 	// Create a pipe to capture stderr
 	r, w, _ := os.Pipe()
@@ -240,8 +229,20 @@ func (u *App) startup(ctx context.Context) {
 		}
 	}()
 
+	u.jobManager.Start(ctx)
+
+	// Forward typed progress events to the frontend
 	go func() {
-		<-ctx.Done()
+		events, unsubscribe := u.jobManager.Subscribe()
+		defer unsubscribe()
+		for {
+			select {
+			case event := <-events:
+				wailsRuntime.EventsEmit(ctx, constants.EventJobProgress, event)
+			case <-ctx.Done():
+				return
+			}
+		}
 	}()
 }
 
@@ -249,14 +250,26 @@ func (u *App) startup(ctx context.Context) {
 func (u *App) ProcessVideosFromUpload(requests []*datatransfers.InputFileRequest) map[string]string {
 	results := make(map[string]string)
 
-	rootTempDir := utils.GetSessionValue(u.sessionApps, constants.CtxKeyRootTempDir)
+	// Jobs live outside the session temp dir so they can be resumed after a restart
+	jobsFolder, err := u.jobsFolder()
+	if err != nil {
+		for _, request := range requests {
+			results[request.FileName] = "Failed to create jobs folder: " + err.Error()
+		}
+		return results
+	}
+
+	jobIDs := make(map[string]string) // file name -> job ID
 
-	// Create a cancellable context
-	ctx, cancel := context.WithCancel(u.ctx)
-	cancelFunc = cancel // Store cancel function globally
+	outputFolder, err := u.outputFolder()
+	if err != nil {
+		for _, request := range requests {
+			results[request.FileName] = "Failed to create output folder: " + err.Error()
+		}
+		return results
+	}
 
-	outputFolder, _ := utils.GetOutputVideoFolder()
-	for i, request := range requests {
+	for _, request := range requests {
 		// Decode Base64 to []byte
 		fileBytes, err := base64.StdEncoding.DecodeString(request.FileBase64)
 		if err != nil {
@@ -264,17 +277,19 @@ func (u *App) ProcessVideosFromUpload(requests []*datatransfers.InputFileRequest
 			continue
 		}
 
-		tempDir, err := os.MkdirTemp(rootTempDir, fmt.Sprintf("%d", i))
-		if err != nil {
-			wailsRuntime.LogError(ctx, fmt.Sprintf("failed create temp dir : %v", err))
+		// The job dir name doubles as the job ID
+		tempDir := filepath.Join(jobsFolder, uuid.New().String())
+		if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+			wailsRuntime.LogError(u.ctx, fmt.Sprintf("failed create temp dir : %v", err))
 			continue
 		}
 
 		// Save file to temp directory
-		tempFilePath := fmt.Sprintf("%s\\%s", tempDir, request.FileName)
+		tempFilePath := filepath.Join(tempDir, request.FileName)
 		err = os.WriteFile(tempFilePath, fileBytes, 0644)
 		if err != nil {
 			results[request.FileName] = "Failed to save: " + err.Error()
+			os.RemoveAll(tempDir)
 			continue
 		}
 
@@ -282,13 +297,13 @@ func (u *App) ProcessVideosFromUpload(requests []*datatransfers.InputFileRequest
 		fileInfo, err := os.Stat(tempFilePath)
 		if err != nil {
 			results[request.FileName] = "Failed to get file info: " + err.Error()
+			os.RemoveAll(tempDir)
 			continue
 		}
 
 		savePath := filepath.Join(outputFolder, fmt.Sprintf("%d_upscaled_", utils.NowUnix())+request.FileName)
 
-		// Process video
-		err = u.videoUpscaler.UpscaleVideoWithRealESRGAN(ctx, &datatransfers.VideoUpscalerRequest{
+		upscaleRequest := &datatransfers.VideoUpscalerRequest{
 			InputPlainFileName: strings.TrimSuffix(fileInfo.Name(), filepath.Ext(tempFilePath)),
 			InputFullFileName:  fileInfo.Name(),
 			InputFileExt:       filepath.Ext(tempFilePath),
@@ -298,12 +313,23 @@ func (u *App) ProcessVideosFromUpload(requests []*datatransfers.InputFileRequest
 			Model:              request.Model,
 			SavePath:           savePath,
 			ScaleMultiplier:    request.Scale,
-		})
-		if err != nil {
+			Streaming:          request.Streaming,
+			Target:             request.Target,
+			StartTime:          request.StartTime,
+			EndTime:            request.EndTime,
+		}
+		if err := u.prepareRequest(upscaleRequest, request.Preset); err != nil {
 			results[request.FileName] = "Failed: " + err.Error()
-		} else {
-			results[request.FileName] = "Success: " + savePath
+			os.RemoveAll(tempDir)
+			continue
 		}
+
+		// Queue video, jobs run one after another
+		jobIDs[request.FileName] = u.jobManager.Submit(upscaleRequest)
+	}
+
+	for fileName, jobID := range jobIDs {
+		results[fileName] = jobResultMessage(u.jobManager.Wait(jobID))
 	}
 
 	for _, v := range results {
@@ -313,25 +339,225 @@ func (u *App) ProcessVideosFromUpload(requests []*datatransfers.InputFileRequest
 	return results
 }
 
+// jobResultMessage formats a finished job the way the frontend expects it
+func jobResultMessage(info datatransfers.JobInfo, err error) string {
+	if err != nil {
+		return "Failed: " + err.Error()
+	}
+
+	switch info.State {
+	case constants.JobStateDone:
+		return "Success: " + info.SavePath
+	case constants.JobStateCancelled:
+		return "Cancelled"
+	default:
+		return "Failed: " + info.Error
+	}
+}
+
+// CancelProcessing cancels every queued and running job
 func (u *App) CancelProcessing() {
-	if cancelFunc != nil {
-		cancelFunc() // Cancel all running tasks
-		fmt.Println("Processing canceled by user.")
-		logger.Warning("Processing canceled by user")
-		cancelFunc = nil // Reset cancelFunc to avoid conflicts
+	u.CancelAll()
+}
+
+// CancelJob cancels a single queued or running job, the other jobs keep going
+func (u *App) CancelJob(jobID string) error {
+	return u.jobManager.CancelJob(jobID)
+}
+
+// CancelAll cancels every queued and running job
+func (u *App) CancelAll() {
+	u.jobManager.CancelAll()
+	logger.Warning("Processing canceled by user")
+}
+
+// ListJobs returns every job of the session with its state
+func (u *App) ListJobs() []datatransfers.JobInfo {
+	return u.jobManager.ListJobs()
+}
+
+// ListResumableJobs returns the jobs that were interrupted before finishing
+func (u *App) ListResumableJobs() ([]*datatransfers.JobManifest, error) {
+	jobsFolder, err := u.jobsFolder()
+	if err != nil {
+		return nil, err
+	}
+	return u.videoUpscaler.ListResumableJobs(jobsFolder)
+}
+
+// ResumeJob continues an interrupted job, only the unfinished batches are upscaled
+func (u *App) ResumeJob(jobID string) string {
+	jobsFolder, err := u.jobsFolder()
+	if err != nil {
+		return "Failed: " + err.Error()
 	}
+
+	jobDir := filepath.Join(jobsFolder, filepath.Base(jobID))
+	return jobResultMessage(u.jobManager.Wait(u.jobManager.SubmitResume(jobDir)))
 }
 
-func (u *App) CleanupRootTempFolder() {
-	rootTempDir := utils.GetSessionValue(u.sessionApps, constants.CtxKeyRootTempDir)
-	err := os.RemoveAll(rootTempDir)
+// DiscardJob deletes a failed, cancelled or interrupted job with its files, it can't be resumed afterwards
+func (u *App) DiscardJob(jobID string) error {
+	jobsFolder, err := u.jobsFolder()
 	if err != nil {
-		logger.Error(err.Error())
+		return err
 	}
+	return u.jobManager.DiscardJob(filepath.Join(jobsFolder, filepath.Base(jobID)))
+}
+
+// pruneJobDirs removes the job dirs left by runs that crashed before a job could be resumed
+func (u *App) pruneJobDirs() {
+	jobsFolder, err := u.jobsFolder()
+	if err == nil {
+		err = u.videoUpscaler.PruneJobDirs(jobsFolder)
+	}
+	if err != nil {
+		logger.Warning(fmt.Sprintf("⚠️ Failed to prune job dirs: %v", err))
+	}
+}
+
+// StartAPIServer starts the loopback HTTP API on the given port (0 picks a free one).
+// An empty token generates a random one, it is returned in the status.
+func (u *App) StartAPIServer(port int, token string) (*datatransfers.APIServerStatus, error) {
+	u.servicesMu.Lock()
+	defer u.servicesMu.Unlock()
+
+	if u.apiServer != nil {
+		return u.apiServerStatusLocked(), nil
+	}
+
+	server, err := u.newAPIServer(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.Start(port); err != nil {
+		return nil, err
+	}
+
+	u.apiServer = server
+	return u.apiServerStatusLocked(), nil
+}
+
+// StopAPIServer stops the HTTP API, running jobs are not affected
+func (u *App) StopAPIServer() error {
+	u.servicesMu.Lock()
+	server := u.apiServer
+	u.apiServer = nil
+	u.servicesMu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+// GetAPIServerStatus reports whether the HTTP API is running and where
+func (u *App) GetAPIServerStatus() *datatransfers.APIServerStatus {
+	u.servicesMu.Lock()
+	defer u.servicesMu.Unlock()
+	return u.apiServerStatusLocked()
+}
+
+func (u *App) apiServerStatusLocked() *datatransfers.APIServerStatus {
+	if u.apiServer == nil {
+		return &datatransfers.APIServerStatus{}
+	}
+	return &datatransfers.APIServerStatus{
+		Running: true,
+		Address: "http://" + u.apiServer.Addr(),
+		Token:   u.apiServer.Token(),
+	}
+}
+
+func (u *App) newAPIServer(token string) (*api.Server, error) {
+	jobsFolder, err := u.jobsFolder()
+	if err != nil {
+		return nil, err
+	}
+	outputFolder, err := u.outputFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		if token, err = api.GenerateToken(); err != nil {
+			return nil, err
+		}
+	}
+	return api.NewServer(logger, u.jobManager, jobsFolder, outputFolder, token, u.prepareRequest), nil
+}
+
+// StartWatchFolder starts auto-enqueuing the videos dropped into config.Folder,
+// replacing the running watcher if any.
+func (u *App) StartWatchFolder(config datatransfers.WatchFolderConfig) (*datatransfers.WatchFolderConfig, error) {
+	jobsFolder, err := u.jobsFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.OutputFolder == "" {
+		if config.OutputFolder, err = u.outputFolder(); err != nil {
+			return nil, err
+		}
+	}
+
+	watcher, err := backend.NewFolderWatcher(logger, u.jobManager, jobsFolder, config, u.prepareRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	u.servicesMu.Lock()
+	defer u.servicesMu.Unlock()
+	if u.folderWatcher != nil {
+		u.folderWatcher.Stop()
+	}
+	watcher.Start(u.ctx)
+	u.folderWatcher = watcher
+
+	watchConfig := watcher.Config()
+	return &watchConfig, nil
+}
+
+// StopWatchFolder stops watching, jobs already queued keep running
+func (u *App) StopWatchFolder() {
+	u.stopWatchFolder()
+}
+
+// stopWatchFolder stops the running watcher and returns it, nil when not watching.
+func (u *App) stopWatchFolder() *backend.FolderWatcher {
+	u.servicesMu.Lock()
+	defer u.servicesMu.Unlock()
+
+	watcher := u.folderWatcher
+	if watcher != nil {
+		watcher.Stop()
+		u.folderWatcher = nil
+	}
+	return watcher
+}
+
+// GetWatchFolderConfig returns the running watcher configuration, nil when not watching
+func (u *App) GetWatchFolderConfig() *datatransfers.WatchFolderConfig {
+	u.servicesMu.Lock()
+	defer u.servicesMu.Unlock()
+
+	if u.folderWatcher == nil {
+		return nil
+	}
+	watchConfig := u.folderWatcher.Config()
+	return &watchConfig
+}
+
+// GetToolDiagnostics reports where each external tool was found, or why not
+func (u *App) GetToolDiagnostics() []datatransfers.ToolDiagnostic {
+	return u.toolDiagnostics
 }
 
 func (a *App) OpenOutputFolder() error {
-	outputFolder, err := utils.GetOutputVideoFolder()
+	outputFolder, err := a.outputFolder()
 	if err != nil {
 		return err
 	}
@@ -341,11 +567,10 @@ func (a *App) OpenOutputFolder() error {
 	switch runtime.GOOS {
 	case "windows":
 		cmd = exec.Command("explorer", outputFolder)
-		// only support windows for now
-	// case "darwin": // macOS
-	// 	cmd = exec.Command("open", outputFolder)
-	// case "linux":
-	// 	cmd = exec.Command("xdg-open", outputFolder)
+	case "darwin": // macOS
+		cmd = exec.Command("open", outputFolder)
+	case "linux":
+		cmd = exec.Command("xdg-open", outputFolder)
 	default:
 		return nil // Unsupported OS
 	}
@@ -364,7 +589,6 @@ func (u *App) gracefulShutdown() {
 
 	go func() {
 		<-s
-		u.CleanupRootTempFolder() // Cleanup before exiting
 		fmt.Println("Shutting down gracefully.")
 		os.Exit(0)
 	}()
@@ -396,14 +620,20 @@ func main() {
 	logger.Info("App Name : " + appName)
 	logger.Info("Session ID : " + uuid)
 
+	// Headless modes, no window is started
+	if len(os.Args) > 1 {
+		if runCommand, ok := app.cliCommands()[os.Args[1]]; ok {
+			exitCode := runCommand(os.Args[2:])
+			logger.Close()
+			os.Exit(exitCode)
+		}
+	}
+
 	go app.gracefulShutdown()
 
-	// Ensure temp files are deleted on exit
-	defer app.CleanupRootTempFolder()
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Error(fmt.Sprintf("App crashed: %v", r))
-			app.CleanupRootTempFolder()
 		}
 	}()
 
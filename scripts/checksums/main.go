@@ -0,0 +1,112 @@
+// Command checksums regenerates embeds/checksums.sha256, run it through
+// `go generate` from the repository root whenever an embedded file changes.
+// Git LFS files are listed with the checksum of their content, also when only
+// the pointer is checked out. Embedded tools missing from the checkout are
+// skipped with a warning, -require-tools makes them an error for release builds.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	embedsDir     = "embeds"
+	checksumsFile = "embeds/checksums.sha256"
+	lfsPointer    = "version https://git-lfs.github.com/spec/v1"
+)
+
+// embeddedTools are embedded by the windows build, which cannot verify them without a checksum.
+// They are not all in the repository, realesrgan-ncnn-vulkan.exe is added by the release build.
+var embeddedTools = []string{
+	"embeds/ffmpeg/ffmpeg.exe",
+	"embeds/ffmpeg/ffprobe.exe",
+	"embeds/realesrgan/realesrgan-ncnn-vulkan.exe",
+}
+
+func main() {
+	requireTools := flag.Bool("require-tools", false, "fail when an embedded tool is missing instead of leaving it out")
+	flag.Parse()
+
+	for _, path := range embeddedTools {
+		if _, err := os.Stat(path); err != nil {
+			if *requireTools {
+				log.Fatalf("embedded tool %s is missing: %v", path, err)
+			}
+			log.Printf("embedded tool %s is missing, it is left out of the checksums", path)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by scripts/checksums, DO NOT EDIT.\n")
+
+	err := filepath.WalkDir(embedsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		path = filepath.ToSlash(path)
+		if path == checksumsFile {
+			return nil
+		}
+
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&sb, "%s  %s\n", sum, path)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(checksumsFile, []byte(sb.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	head := make([]byte, len(lfsPointer))
+	n, _ := io.ReadFull(file, head)
+	if bytes.Equal(head[:n], []byte(lfsPointer)) {
+		return lfsChecksum(path, file)
+	}
+
+	hash.Write(head[:n])
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// lfsChecksum returns the oid of a Git LFS pointer, the SHA-256 of the file it points to.
+func lfsChecksum(path string, pointer io.Reader) (string, error) {
+	scanner := bufio.NewScanner(pointer)
+	for scanner.Scan() {
+		if oid, ok := strings.CutPrefix(scanner.Text(), "oid sha256:"); ok && len(oid) == sha256.Size*2 {
+			return oid, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s is a Git LFS pointer without a sha256 oid", path)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/riskibarqy/RevivePixels/backend"
+	"github.com/riskibarqy/RevivePixels/backend/constants"
+	"github.com/riskibarqy/RevivePixels/backend/datatransfers"
+	"github.com/riskibarqy/RevivePixels/backend/settings"
+	"github.com/riskibarqy/RevivePixels/backend/utils"
+	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// loadSettings opens the settings store in the user config dir, a broken
+// settings file is reported and replaced by the defaults on the next save.
+func (u *App) loadSettings(appName string) error {
+	path, err := settings.DefaultPath(appName)
+	if err != nil {
+		return fmt.Errorf("failed to locate settings: %v", err)
+	}
+
+	store, err := settings.NewStore(path)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("⚠️ %v, using defaults", err))
+	}
+	u.settings = store
+	return nil
+}
+
+func (u *App) currentSettings() datatransfers.Settings {
+	if u.settings == nil {
+		return settings.Defaults()
+	}
+	return u.settings.Get()
+}
+
+// jobsFolder returns the folder holding the job data, under the configured
+// temp location when there is one.
+func (u *App) jobsFolder() (string, error) {
+	tempFolder := u.currentSettings().TempFolder
+	if tempFolder == "" {
+		return utils.GetJobsFolder(utils.GetSessionValue(u.sessionApps, constants.CtxAppName))
+	}
+
+	jobsFolder := filepath.Join(tempFolder, constants.JobsFolderName)
+	if err := os.MkdirAll(jobsFolder, os.ModePerm); err != nil {
+		return "", err
+	}
+	return jobsFolder, nil
+}
+
+// outputFolder returns the configured output folder, output_videos next to the
+// executable by default or Videos in the user home when that is read-only.
+func (u *App) outputFolder() (string, error) {
+	outputFolder := u.currentSettings().OutputFolder
+	if outputFolder == "" {
+		return utils.GetOutputVideoFolder(utils.GetSessionValue(u.sessionApps, constants.CtxAppName))
+	}
+
+	if err := os.MkdirAll(outputFolder, os.ModePerm); err != nil {
+		return "", err
+	}
+	return outputFolder, nil
+}
+
+// prepareRequest applies the named preset, if any, then fills what is left
+// empty from the settings and validates the result.
+func (u *App) prepareRequest(request *datatransfers.VideoUpscalerRequest, presetName string) error {
+	if err := u.applyRequestDefaults(request, presetName); err != nil {
+		return err
+	}
+	return u.validateRequest(request)
+}
+
+// applyRequestDefaults applies the named preset, if any, then fills what is
+// left empty from the settings.
+func (u *App) applyRequestDefaults(request *datatransfers.VideoUpscalerRequest, presetName string) error {
+	if presetName != "" {
+		if u.settings == nil {
+			return fmt.Errorf("%w: %s", settings.ErrPresetNotFound, presetName)
+		}
+		preset, err := u.settings.Preset(presetName)
+		if err != nil {
+			return err
+		}
+		settings.ApplyPreset(request, preset)
+	}
+
+	settings.ApplyDefaults(request, u.currentSettings())
+	return nil
+}
+
+// validateRequest rejects a request the upscale would fail on, before it is queued.
+func (u *App) validateRequest(request *datatransfers.VideoUpscalerRequest) error {
+	// Reject unsupported encoder, trim, model, resolution and container settings before anything is queued
+	if err := u.videoUpscaler.ValidateEncoder(context.Background(), request.Encoder); err != nil {
+		return err
+	}
+	if err := backend.ValidateTrim(request); err != nil {
+		return err
+	}
+	if err := backend.ValidateTarget(request.Target); err != nil {
+		return err
+	}
+	if err := backend.ValidatePreScale(request.PreScale); err != nil {
+		return err
+	}
+	if err := u.videoUpscaler.ValidateModels(request); err != nil {
+		return err
+	}
+	if err := u.videoUpscaler.ValidateScales(request); err != nil {
+		return err
+	}
+	if err := backend.ValidateSceneThreshold(request.SceneThreshold); err != nil {
+		return err
+	}
+	if err := u.videoUpscaler.ValidateStreaming(request); err != nil {
+		return err
+	}
+	if err := backend.ResolveContainer(request); err != nil {
+		return err
+	}
+
+	// The plan is listed with the job before it starts
+	plan, err := u.videoUpscaler.PlanResolution(context.Background(), request)
+	if err != nil {
+		return err
+	}
+	request.Plan = plan
+	return nil
+}
+
+// GetSettings returns the application settings
+func (u *App) GetSettings() datatransfers.Settings {
+	return u.currentSettings()
+}
+
+// UpdateSettings saves the settings, presets are left untouched
+func (u *App) UpdateSettings(newSettings datatransfers.Settings) error {
+	if err := u.videoUpscaler.ValidateEncoder(u.ctx, newSettings.Encoder); err != nil {
+		return err
+	}
+	return u.settings.Update(newSettings)
+}
+
+// ListEncoders returns the output encoders and whether the ffmpeg in use has them
+func (u *App) ListEncoders() ([]datatransfers.EncoderInfo, error) {
+	return u.videoUpscaler.ListEncoders(u.ctx)
+}
+
+// ListContainers returns the output containers and the encoders each accepts
+func (u *App) ListContainers() []datatransfers.ContainerInfo {
+	return backend.ListContainers()
+}
+
+// ListPresets returns every saved preset
+func (u *App) ListPresets() []datatransfers.Preset {
+	return u.currentSettings().Presets
+}
+
+// SavePreset creates a preset when originalName is empty, otherwise edits it
+func (u *App) SavePreset(originalName string, preset datatransfers.Preset) error {
+	// An empty codec means the settings encoder, validated when the settings are saved
+	if preset.Encoder.Codec != "" {
+		if err := u.videoUpscaler.ValidateEncoder(u.ctx, preset.Encoder); err != nil {
+			return err
+		}
+	}
+	return u.settings.SavePreset(originalName, preset)
+}
+
+// DeletePreset removes a preset
+func (u *App) DeletePreset(name string) error {
+	return u.settings.DeletePreset(name)
+}
+
+// ExportPresets asks where to save the named presets, all of them when names is empty.
+// It returns the chosen path, empty when the dialog was cancelled.
+func (u *App) ExportPresets(names []string) (string, error) {
+	path, err := wailsRuntime.SaveFileDialog(u.ctx, wailsRuntime.SaveDialogOptions{
+		Title:           "Export presets",
+		DefaultFilename: "revivepixels-presets.json",
+		Filters:         []wailsRuntime.FileFilter{{DisplayName: "Presets (*.json)", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	if err := u.settings.ExportPresets(path, names); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ImportPresets asks for an exported presets file and adds its presets,
+// replacing the ones with the same name
+func (u *App) ImportPresets() ([]datatransfers.Preset, error) {
+	path, err := wailsRuntime.OpenFileDialog(u.ctx, wailsRuntime.OpenDialogOptions{
+		Title:   "Import presets",
+		Filters: []wailsRuntime.FileFilter{{DisplayName: "Presets (*.json)", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	return u.settings.ImportPresets(path)
+}